@@ -7,6 +7,7 @@ require (
 	github.com/gorilla/mux v1.8.1
 	github.com/jackc/pgx/v5 v5.8.0
 	golang.org/x/crypto v0.47.0
+	golang.org/x/time v0.12.0
 )
 
 require (
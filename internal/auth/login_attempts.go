@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxLoginAttempts = 5
+	defaultLoginLockout     = 15 * time.Minute
+)
+
+// AccountLockedError is returned by Authenticate while an account is locked after
+// too many failed logins.
+type AccountLockedError struct {
+	RetryAfter time.Duration // time left until the lock expires
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("account locked after too many failed logins; retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+type loginAttempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// LoginAttemptTracker counts consecutive failed logins per account and locks the
+// account for a while once maxAttempts is reached. State is kept in memory, so it is
+// per instance and reset by a restart.
+type LoginAttemptTracker struct {
+	mu           sync.Mutex
+	attempts     map[string]*loginAttempts
+	maxAttempts  int
+	lockDuration time.Duration
+}
+
+// NewLoginAttemptTracker creates a tracker that locks an account for lockDuration
+// after maxAttempts consecutive failures (defaults 5 and 15m for values <= 0), and
+// starts a background goroutine that forgets stale entries.
+func NewLoginAttemptTracker(maxAttempts int, lockDuration time.Duration) *LoginAttemptTracker {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxLoginAttempts
+	}
+	if lockDuration <= 0 {
+		lockDuration = defaultLoginLockout
+	}
+	t := &LoginAttemptTracker{
+		attempts:     make(map[string]*loginAttempts),
+		maxAttempts:  maxAttempts,
+		lockDuration: lockDuration,
+	}
+	go t.cleanup()
+	return t
+}
+
+// LockedFor returns how long key stays locked, or 0 if it isn't.
+func (t *LoginAttemptTracker) LockedFor(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, ok := t.attempts[key]
+	if !ok {
+		return 0
+	}
+	return max(time.Until(a.lockedUntil), 0)
+}
+
+// RecordFailure counts a failed login for key. When it reaches the limit the key is
+// locked and the lock duration is returned; otherwise it returns 0.
+func (t *LoginAttemptTracker) RecordFailure(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, ok := t.attempts[key]
+	if !ok {
+		a = &loginAttempts{}
+		t.attempts[key] = a
+	}
+	a.failures++
+	a.lastFailure = time.Now()
+	if a.failures < t.maxAttempts {
+		return 0
+	}
+	a.failures = 0
+	a.lockedUntil = a.lastFailure.Add(t.lockDuration)
+	return t.lockDuration
+}
+
+// Reset clears key's failures after a successful login.
+func (t *LoginAttemptTracker) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.attempts, key)
+}
+
+// cleanup periodically drops entries that are unlocked and have had no failure for
+// a lock duration, so the map doesn't grow with every identifier ever tried.
+func (t *LoginAttemptTracker) cleanup() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		t.mu.Lock()
+		for key, a := range t.attempts {
+			if now.After(a.lockedUntil) && now.Sub(a.lastFailure) > t.lockDuration {
+				delete(t.attempts, key)
+			}
+		}
+		t.mu.Unlock()
+	}
+}
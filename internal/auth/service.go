@@ -1,7 +1,14 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"cooking-app/internal/models"
@@ -12,28 +19,118 @@ import (
 
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrWeakPassword       = errors.New("password must be at least 6 characters")
+	ErrWeakPassword       = errors.New("password too weak")
+
+	// Token validation failures, returned by ValidateToken.
+	ErrTokenExpired       = errors.New("token expired")
+	ErrTokenMalformed     = errors.New("token malformed")
+	ErrTokenMissingExpiry = errors.New("token has no exp claim")
+	ErrTokenMissingIssuer = errors.New("token has no iss claim")
+	ErrTokenWrongIssuer   = errors.New("token issuer mismatch")
+	ErrTokenRevoked       = errors.New("token revoked")
+	ErrTokenInvalid       = errors.New("token invalid")
+)
+
+const (
+	defaultAccessTokenTTL  = 24 * time.Hour
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	defaultIssuer          = "cooking-app"
+	defaultPasswordMinLen  = 8
 )
 
+// Options configures token lifetimes and issuer. Zero values use the defaults.
+type Options struct {
+	AccessTokenTTL  time.Duration // JWT lifetime (default 24h)
+	RefreshTokenTTL time.Duration // opaque refresh token lifetime (default 30 days)
+	Issuer          string        // iss claim set and required on tokens (default "cooking-app")
+	PasswordPolicy  PasswordPolicy
+	// Users enables Authenticate. Its lookups must return an error matching
+	// UserNotFound (via errors.Is) for unknown accounts.
+	Users        UserStore
+	UserNotFound error
+	// Revocations enables RevokeToken; ValidateToken then rejects revoked tokens.
+	Revocations RevocationStore
+	// MaxLoginAttempts consecutive failed logins lock an account for LoginLockout
+	// (defaults 5 and 15m).
+	MaxLoginAttempts int
+	LoginLockout     time.Duration
+}
+
+// UserStore looks up accounts for Authenticate.
+type UserStore interface {
+	GetByUsername(username string) (*models.User, error)
+	GetByEmail(email string) (*models.User, error)
+}
+
+// RevocationStore records access tokens revoked before they expire, keyed by jti.
+type RevocationStore interface {
+	RevokeToken(jti string, expiresAt time.Time) error
+	IsTokenRevoked(jti string) (bool, error)
+}
+
+// PasswordPolicy is the set of rules new passwords must satisfy.
+type PasswordPolicy struct {
+	MinLength      int  // minimum length in characters (default 8)
+	RequireDigit   bool // at least one 0-9
+	RequireUpper   bool // at least one uppercase letter
+	RequireSpecial bool // at least one character that is not a letter, digit or space
+}
+
+// Claims is the single JWT claims shape issued and accepted by the app.
+type Claims struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	jwt.RegisteredClaims
+}
+
 // Service handles authentication logic.
 type Service struct {
-	jwtSecret []byte
+	jwtSecret       []byte
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	issuer          string
+	passwordPolicy  PasswordPolicy
+	users           UserStore
+	userNotFound    error
+	revocations     RevocationStore
+	loginAttempts   *LoginAttemptTracker
 }
 
 // NewService creates a new auth service.
-func NewService(jwtSecret string) *Service {
+func NewService(jwtSecret string, opts Options) *Service {
 	if jwtSecret == "" {
 		jwtSecret = "default-secret-change-in-production"
 	}
+	if opts.AccessTokenTTL <= 0 {
+		opts.AccessTokenTTL = defaultAccessTokenTTL
+	}
+	if opts.RefreshTokenTTL <= 0 {
+		opts.RefreshTokenTTL = defaultRefreshTokenTTL
+	}
+	if opts.Issuer == "" {
+		opts.Issuer = defaultIssuer
+	}
+	if opts.PasswordPolicy.MinLength <= 0 {
+		opts.PasswordPolicy.MinLength = defaultPasswordMinLen
+	}
 	return &Service{
-		jwtSecret: []byte(jwtSecret),
+		jwtSecret:       []byte(jwtSecret),
+		accessTokenTTL:  opts.AccessTokenTTL,
+		refreshTokenTTL: opts.RefreshTokenTTL,
+		issuer:          opts.Issuer,
+		passwordPolicy:  opts.PasswordPolicy,
+		users:           opts.Users,
+		userNotFound:    opts.UserNotFound,
+		revocations:     opts.Revocations,
+		loginAttempts:   NewLoginAttemptTracker(opts.MaxLoginAttempts, opts.LoginLockout),
 	}
 }
 
 // HashPassword hashes a password using bcrypt.
 func (s *Service) HashPassword(password string) (string, error) {
-	if len(password) < 6 {
-		return "", ErrWeakPassword
+	if err := s.ValidatePassword(password); err != nil {
+		return "", err
 	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -42,41 +139,193 @@ func (s *Service) HashPassword(password string) (string, error) {
 	return string(hash), nil
 }
 
+// Authenticate checks a login. identifier is an email address when it contains "@",
+// otherwise a username. Unknown accounts and wrong passwords both return
+// ErrInvalidCredentials, and take similar time, so logins can't be used to probe
+// which accounts exist. After too many consecutive failures the account is locked
+// and *AccountLockedError is returned, without checking the password, until the lock
+// expires; unknown identifiers are locked the same way. Other errors come from the
+// user store.
+func (s *Service) Authenticate(identifier, password string) (*models.User, error) {
+	if s.users == nil {
+		return nil, errors.New("auth: Authenticate needs Options.Users")
+	}
+	var user *models.User
+	var err error
+	if strings.Contains(identifier, "@") {
+		user, err = s.users.GetByEmail(identifier)
+	} else {
+		user, err = s.users.GetByUsername(identifier)
+	}
+	if err != nil {
+		if s.userNotFound != nil && errors.Is(err, s.userNotFound) {
+			key := "identifier:" + strings.ToLower(identifier)
+			if locked := s.loginAttempts.LockedFor(key); locked > 0 {
+				return nil, &AccountLockedError{RetryAfter: locked}
+			}
+			// Spend the same bcrypt work as a real check.
+			bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+			return nil, s.loginFailed(key)
+		}
+		return nil, err
+	}
+
+	// Keyed by account, so alternating username and email shares one budget.
+	key := "user:" + strconv.Itoa(user.ID)
+	if locked := s.loginAttempts.LockedFor(key); locked > 0 {
+		return nil, &AccountLockedError{RetryAfter: locked}
+	}
+	if err := s.ComparePassword(user.Password, password); err != nil {
+		return nil, s.loginFailed(key)
+	}
+	s.loginAttempts.Reset(key)
+	return user, nil
+}
+
+// loginFailed records a failed login for key and returns the error to report: the
+// lock if this failure triggered one, otherwise ErrInvalidCredentials.
+func (s *Service) loginFailed(key string) error {
+	if locked := s.loginAttempts.RecordFailure(key); locked > 0 {
+		return &AccountLockedError{RetryAfter: locked}
+	}
+	return ErrInvalidCredentials
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// dummyPasswordHash is compared against when the account doesn't exist.
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+	})
+	return dummyHash
+}
+
 // ComparePassword checks if password matches hash.
 func (s *Service) ComparePassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
-// GenerateToken creates a JWT token for a user.
+// GenerateRandomString returns a cryptographically random hex string of 2*n characters.
+func GenerateRandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashToken returns the SHA-256 hex digest of an opaque token, so only digests are stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateToken creates a JWT token for a user. Each token gets a random jti so it
+// can be revoked on its own.
 func (s *Service) GenerateToken(user *models.User) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"email":    user.Email,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(), // 24 hours
-		"iat":      time.Now().Unix(),
+	jti, err := GenerateRandomString(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        jti,
+		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(s.jwtSecret)
 }
 
-// ValidateToken validates a JWT token and returns claims.
-func (s *Service) ValidateToken(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+// AccessTokenTTL returns how long issued JWTs are valid.
+func (s *Service) AccessTokenTTL() time.Duration {
+	return s.accessTokenTTL
+}
+
+// NewRefreshToken creates an opaque refresh token. Only hash should be stored.
+func (s *Service) NewRefreshToken() (token, hash string, expiresAt time.Time, err error) {
+	token, err = GenerateRandomString(32)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	return token, HashToken(token), time.Now().Add(s.refreshTokenTTL), nil
+}
+
+// ValidateToken validates a JWT token and returns its claims. The token must be
+// HMAC-signed, carry an exp claim and be issued by this service. Failures are
+// reported as one of the ErrToken* errors so callers can tell them apart.
+func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("invalid signing method")
 		}
 		return s.jwtSecret, nil
-	})
+	}, jwt.WithExpirationRequired(), jwt.WithIssuer(s.issuer), jwt.WithIssuedAt())
 
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return nil, ErrTokenExpired
+		case errors.Is(err, jwt.ErrTokenMalformed):
+			return nil, ErrTokenMalformed
+		case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+			// Both a missing exp and a missing iss end up here; tell them apart.
+			if token != nil {
+				if claims, ok := token.Claims.(*Claims); ok && claims.ExpiresAt != nil {
+					return nil, ErrTokenMissingIssuer
+				}
+			}
+			return nil, ErrTokenMissingExpiry
+		case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+			return nil, ErrTokenWrongIssuer
+		default:
+			return nil, fmt.Errorf("%w: %v", ErrTokenInvalid, err)
+		}
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, ErrTokenInvalid
+	}
+	// Tokens issued before jti was added can't be revoked individually.
+	if s.revocations != nil && claims.ID != "" {
+		revoked, err := s.revocations.IsTokenRevoked(claims.ID)
+		if err != nil {
+			return nil, fmt.Errorf("check token revocation: %w", err)
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
 	}
+	return claims, nil
+}
 
-	return nil, errors.New("invalid token")
+// RevokeToken invalidates a valid access token until it would have expired anyway.
+// Revoking an already revoked token succeeds; other ValidateToken failures are returned.
+func (s *Service) RevokeToken(tokenString string) error {
+	if s.revocations == nil {
+		return errors.New("auth: RevokeToken needs Options.Revocations")
+	}
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		if errors.Is(err, ErrTokenRevoked) {
+			return nil
+		}
+		return err
+	}
+	if claims.ID == "" {
+		return fmt.Errorf("%w: token has no jti", ErrTokenInvalid)
+	}
+	return s.revocations.RevokeToken(claims.ID, claims.ExpiresAt.Time)
 }
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestValidateTokenMissingClaims(t *testing.T) {
+	const secret = "test-secret"
+	s := NewService(secret, Options{})
+	exp := jwt.NewNumericDate(time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name   string
+		claims jwt.RegisteredClaims
+		want   error
+	}{
+		{"no exp", jwt.RegisteredClaims{Issuer: defaultIssuer}, ErrTokenMissingExpiry},
+		{"no iss", jwt.RegisteredClaims{ExpiresAt: exp}, ErrTokenMissingIssuer},
+		{"no exp or iss", jwt.RegisteredClaims{}, ErrTokenMissingExpiry},
+		{"wrong iss", jwt.RegisteredClaims{ExpiresAt: exp, Issuer: "someone-else"}, ErrTokenWrongIssuer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: 1, RegisteredClaims: tt.claims}).
+				SignedString([]byte(secret))
+			if err != nil {
+				t.Fatalf("sign: %v", err)
+			}
+			if _, err := s.ValidateToken(token); !errors.Is(err, tt.want) {
+				t.Errorf("ValidateToken error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidEmail    = errors.New("email must be a valid address like name@example.com")
+	ErrInvalidUsername = errors.New("username must be 3-30 characters: letters, digits, '_', '-' or '.'")
+)
+
+// ValidateEmail checks that email is a single bare address with a domain.
+func ValidateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@")+1:], ".") {
+		return ErrInvalidEmail
+	}
+	return nil
+}
+
+// ValidateUsername checks username length and characters.
+func ValidateUsername(username string) error {
+	if len(username) < 3 || len(username) > 30 {
+		return ErrInvalidUsername
+	}
+	for _, r := range username {
+		ok := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.'
+		if !ok {
+			return ErrInvalidUsername
+		}
+	}
+	return nil
+}
+
+// ValidatePassword checks a new password against the configured PasswordPolicy. The
+// error wraps ErrWeakPassword and names every rule the password breaks.
+func (s *Service) ValidatePassword(password string) error {
+	p := s.passwordPolicy
+	var hasDigit, hasUpper, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			hasSpecial = true
+		}
+	}
+
+	var failed []string
+	if utf8.RuneCountInString(password) < p.MinLength {
+		failed = append(failed, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if p.RequireDigit && !hasDigit {
+		failed = append(failed, "must contain a digit")
+	}
+	if p.RequireUpper && !hasUpper {
+		failed = append(failed, "must contain an uppercase letter")
+	}
+	if p.RequireSpecial && !hasSpecial {
+		failed = append(failed, "must contain a special character")
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", ErrWeakPassword, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// ValidateRegistration runs every registration check and returns the failures keyed
+// by JSON field name, or nil when all fields are valid.
+func (s *Service) ValidateRegistration(username, email, password string) map[string]string {
+	errs := make(map[string]string)
+	check := func(field, value string, validate func(string) error) {
+		if value == "" {
+			errs[field] = field + " is required"
+		} else if err := validate(value); err != nil {
+			errs[field] = err.Error()
+		}
+	}
+	check("username", username, ValidateUsername)
+	check("email", email, ValidateEmail)
+	check("password", password, s.ValidatePassword)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
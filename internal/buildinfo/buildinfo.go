@@ -0,0 +1,25 @@
+// Package buildinfo holds version details stamped in at link time:
+//
+//	go build -ldflags "-X cooking-app/internal/buildinfo.Version=v1.2.0 \
+//	  -X cooking-app/internal/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X cooking-app/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" .
+package buildinfo
+
+// Set with -ldflags -X; the defaults mark a build that wasn't stamped.
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info is the JSON form served by GET /api/version.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+}
+
+// Get returns the stamped build details.
+func Get() Info {
+	return Info{Version: Version, Commit: Commit, BuildTime: BuildTime}
+}
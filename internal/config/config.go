@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds runtime settings read from environment variables.
+type Config struct {
+	// ServerHost is the address the HTTP server binds to (SERVER_HOST, default empty,
+	// i.e. all interfaces).
+	ServerHost string
+	// ServerPort is the port the HTTP server listens on (SERVER_PORT, default "8080").
+	// It is kept as given so main can reject values that aren't a valid port.
+	ServerPort string
+	// RateLimitPerMinute is the number of requests a single client IP may make per minute
+	// on rate-limited routes (RATE_LIMIT_PER_MINUTE, default 60).
+	RateLimitPerMinute int
+	// RateLimitBurst is the bucket size, i.e. how many requests may arrive at once
+	// (RATE_LIMIT_BURST, default 10).
+	RateLimitBurst int
+	// LockLegacyRecipes makes recipes created before ownership tracking (user_id NULL)
+	// read-only instead of editable by any logged-in user (LOCK_LEGACY_RECIPES, default false).
+	LockLegacyRecipes bool
+	// RequireEmailVerification blocks recipe creation until the user has verified their
+	// email (REQUIRE_EMAIL_VERIFICATION, default false).
+	RequireEmailVerification bool
+	// AccessTokenTTL is the JWT lifetime (ACCESS_TOKEN_TTL, e.g. "15m", default 24h).
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is the refresh token lifetime (REFRESH_TOKEN_TTL, default 720h).
+	RefreshTokenTTL time.Duration
+	// JWTIssuer is written to and required in the iss claim (JWT_ISSUER, default "cooking-app").
+	JWTIssuer string
+	// CORSOrigins lists the origins allowed to call the API (CORS_ORIGINS, comma-separated;
+	// "*" allows any origin without credentials). Defaults to local development origins.
+	CORSOrigins []string
+	// MaxCommentLength is the longest comment, in characters, accepted after trimming
+	// (COMMENT_MAX_LENGTH, default 2000).
+	MaxCommentLength int
+	// CommentBlocklist lists words that cause a comment to be rejected
+	// (COMMENT_BLOCKLIST, comma-separated, default empty).
+	CommentBlocklist []string
+	// PasswordMinLength is the minimum length of new passwords (PASSWORD_MIN_LENGTH, default 8).
+	PasswordMinLength int
+	// PasswordRequireDigit, PasswordRequireUpper and PasswordRequireSpecial add character
+	// class rules for new passwords (PASSWORD_REQUIRE_DIGIT, PASSWORD_REQUIRE_UPPER,
+	// PASSWORD_REQUIRE_SPECIAL, default false).
+	PasswordRequireDigit   bool
+	PasswordRequireUpper   bool
+	PasswordRequireSpecial bool
+	// DBMaxOpenConns, DBMaxIdleConns and DBConnMaxLifetime size the PostgreSQL
+	// connection pool (DB_MAX_OPEN_CONNS default 25, DB_MAX_IDLE_CONNS default 5,
+	// DB_CONN_MAX_LIFETIME default 5m).
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	// RatingCacheRefreshInterval is how often cached rating stats are rebuilt from the
+	// ratings table (RATING_CACHE_REFRESH_INTERVAL, default 10m).
+	RatingCacheRefreshInterval time.Duration
+	// RevokedTokenCleanupInterval is how often revoked access tokens past their expiry
+	// are purged (REVOKED_TOKEN_CLEANUP_INTERVAL, default 1h).
+	RevokedTokenCleanupInterval time.Duration
+	// LoginMaxAttempts consecutive failed logins lock an account for LoginLockoutDuration
+	// (LOGIN_MAX_ATTEMPTS, default 5; LOGIN_LOCKOUT_DURATION, default 15m).
+	LoginMaxAttempts     int
+	LoginLockoutDuration time.Duration
+	// MailOutboxDir, when set, makes account emails (password reset, verification) be
+	// written as files into this directory instead of discarded; meant for development
+	// (MAIL_OUTBOX_DIR, default empty).
+	MailOutboxDir string
+	// MaxRequestBodyBytes is the largest request body accepted; bigger ones get 413
+	// (MAX_REQUEST_BODY_BYTES, default 1048576).
+	MaxRequestBodyBytes int
+}
+
+// Load reads configuration from the environment, falling back to defaults.
+func Load() *Config {
+	return &Config{
+		ServerHost: getEnvString("SERVER_HOST", ""),
+		ServerPort: getEnvString("SERVER_PORT", "8080"),
+
+		RateLimitPerMinute: getEnvInt("RATE_LIMIT_PER_MINUTE", 60),
+		RateLimitBurst:     getEnvInt("RATE_LIMIT_BURST", 10),
+		LockLegacyRecipes:  getEnvBool("LOCK_LEGACY_RECIPES", false),
+
+		RequireEmailVerification: getEnvBool("REQUIRE_EMAIL_VERIFICATION", false),
+		AccessTokenTTL:           getEnvDuration("ACCESS_TOKEN_TTL", 24*time.Hour),
+		RefreshTokenTTL:          getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		JWTIssuer:                getEnvString("JWT_ISSUER", "cooking-app"),
+		CORSOrigins:              getEnvList("CORS_ORIGINS", []string{"http://localhost:8080", "http://localhost:3000"}),
+		MaxCommentLength:         getEnvInt("COMMENT_MAX_LENGTH", 2000),
+		CommentBlocklist:         getEnvList("COMMENT_BLOCKLIST", nil),
+		PasswordMinLength:        getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordRequireDigit:     getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+		PasswordRequireUpper:     getEnvBool("PASSWORD_REQUIRE_UPPER", false),
+		PasswordRequireSpecial:   getEnvBool("PASSWORD_REQUIRE_SPECIAL", false),
+		DBMaxOpenConns:           getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:           getEnvInt("DB_MAX_IDLE_CONNS", 5),
+		DBConnMaxLifetime:        getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+
+		RatingCacheRefreshInterval:  getEnvDuration("RATING_CACHE_REFRESH_INTERVAL", 10*time.Minute),
+		RevokedTokenCleanupInterval: getEnvDuration("REVOKED_TOKEN_CLEANUP_INTERVAL", time.Hour),
+		MaxRequestBodyBytes:         getEnvInt("MAX_REQUEST_BODY_BYTES", 1<<20),
+		MailOutboxDir:               getEnvString("MAIL_OUTBOX_DIR", ""),
+		LoginMaxAttempts:            getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginLockoutDuration:        getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+	}
+}
+
+func getEnvList(key string, fallback []string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	if len(list) == 0 {
+		return fallback
+	}
+	return list
+}
+
+func getEnvString(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fallback
+	}
+	return b
+}
+
+func getEnvInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,484 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// migration is one numbered schema change. Each runs once, in its own transaction,
+// and its version is recorded in schema_migrations.
+//
+// To change the schema, append a new entry with the next version number. Never
+// edit, renumber or remove a migration that has already shipped.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{1, "create tables", createTables},
+	{2, "upgrade pre-migration schemas", upgradeLegacySchema},
+	{3, "create indexes", createIndexes},
+	{4, "add ratings.cooked", addRatingsCooked},
+	{5, "index recipe names per user", indexRecipeNamesPerUser},
+	{6, "create recipe_rating_cache", createRatingCache},
+	{7, "add recipes.updated_at", addRecipesUpdatedAt},
+	{8, "add recipes.view_count", addRecipesViewCount},
+	{9, "create revoked_tokens", createRevokedTokens},
+	{10, "add recipe steps", addRecipeSteps},
+	{11, "add recipes.orphaned", addRecipesOrphaned},
+}
+
+// migrationLockID is the advisory lock key that keeps concurrently starting
+// instances from applying the same migration twice.
+const migrationLockID = 727274001
+
+// runMigrations applies every migration newer than the database's recorded version.
+func runMigrations(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	current := 0
+	for _, m := range migrations {
+		applied, err := applyMigration(db, m)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		if applied {
+			log.Printf("✓ Applied migration %d: %s", m.version, m.name)
+		}
+		current = m.version
+	}
+
+	log.Printf("✓ Database schema at version %d", current)
+	return nil
+}
+
+// applyMigration runs m unless it is already recorded, reporting whether it ran.
+func applyMigration(db *sql.DB, m migration) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return false, err
+	}
+
+	var done bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`,
+		m.version).Scan(&done); err != nil {
+		return false, err
+	}
+	if done {
+		return false, nil
+	}
+
+	if err := m.up(tx); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+		m.version, m.name); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// execOptional runs q under a savepoint so that a failure is returned without
+// aborting the surrounding migration transaction.
+func execOptional(tx *sql.Tx, q string) error {
+	if _, err := tx.Exec(`SAVEPOINT optional_step`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(q); err != nil {
+		if _, rbErr := tx.Exec(`ROLLBACK TO SAVEPOINT optional_step`); rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+	_, err := tx.Exec(`RELEASE SAVEPOINT optional_step`)
+	return err
+}
+
+func createTables(tx *sql.Tx) error {
+	tables := []string{
+		`CREATE TABLE IF NOT EXISTS users (
+			id SERIAL PRIMARY KEY,
+			username TEXT NOT NULL UNIQUE,
+			email TEXT NOT NULL UNIQUE,
+			password TEXT NOT NULL DEFAULT '',
+			first_name TEXT,
+			last_name TEXT,
+			bio TEXT,
+			email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+			is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS ingredients (
+			id SERIAL PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE
+		)`,
+		`CREATE TABLE IF NOT EXISTS recipes (
+			id SERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			description TEXT,
+			instructions TEXT,
+			prep_time_min INT NOT NULL DEFAULT 0,
+			cook_time_min INT NOT NULL DEFAULT 0,
+			user_id INT REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			calories INT,
+			protein_g NUMERIC(8,2),
+			carbs_g NUMERIC(8,2),
+			fat_g NUMERIC(8,2),
+			deleted_at TIMESTAMPTZ,
+			servings INT NOT NULL DEFAULT 1,
+			difficulty TEXT NOT NULL DEFAULT 'medium' CHECK (difficulty IN ('easy', 'medium', 'hard'))
+		)`,
+		`CREATE TABLE IF NOT EXISTS recipe_ingredients (
+			recipe_id INT NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+			ingredient_id INT NOT NULL REFERENCES ingredients(id),
+			quantity TEXT NOT NULL,
+			PRIMARY KEY (recipe_id, ingredient_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS ratings (
+			id SERIAL PRIMARY KEY,
+			recipe_id INT NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			rating INT NOT NULL CHECK (rating >= 1 AND rating <= 5),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE(recipe_id, user_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS comments (
+			id SERIAL PRIMARY KEY,
+			recipe_id INT NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			parent_id INT REFERENCES comments(id) ON DELETE CASCADE,
+			content TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS password_reset_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash TEXT NOT NULL UNIQUE,
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS email_verification_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash TEXT NOT NULL UNIQUE,
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash TEXT NOT NULL UNIQUE,
+			expires_at TIMESTAMPTZ NOT NULL,
+			revoked_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS favorites (
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			recipe_id INT NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+			saved_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (user_id, recipe_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS comment_reports (
+			id SERIAL PRIMARY KEY,
+			comment_id INT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+			reporter_user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			reason TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE(comment_id, reporter_user_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS recipe_versions (
+			id SERIAL PRIMARY KEY,
+			recipe_id INT NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+			version INT NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT,
+			instructions TEXT,
+			prep_time_min INT NOT NULL DEFAULT 0,
+			cook_time_min INT NOT NULL DEFAULT 0,
+			servings INT NOT NULL DEFAULT 1,
+			difficulty TEXT NOT NULL DEFAULT 'medium',
+			ingredients JSONB NOT NULL DEFAULT '[]',
+			replaced_by INT REFERENCES users(id) ON DELETE SET NULL,
+			replaced_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE(recipe_id, version)
+		)`,
+	}
+	for _, q := range tables {
+		if _, err := tx.Exec(q); err != nil {
+			return fmt.Errorf("create table: %w", err)
+		}
+	}
+	return nil
+}
+
+// upgradeLegacySchema brings databases created before schema_migrations existed
+// up to the shape createTables produces. Those databases can be at any earlier
+// state, so each step still checks the catalog; on a fresh database they are all
+// no-ops. It runs once, like every other migration.
+func upgradeLegacySchema(tx *sql.Tx) error {
+	if err := addPasswordColumnIfMissing(tx); err != nil {
+		return err
+	}
+
+	if err := addUniqueConstraintsIfMissing(tx); err != nil {
+		return err
+	}
+
+	if err := addRecipeUserIDIfMissing(tx); err != nil {
+		return err
+	}
+
+	nutritionColumns := []struct{ name, definition string }{
+		{"calories", "INT"},
+		{"protein_g", "NUMERIC(8,2)"},
+		{"carbs_g", "NUMERIC(8,2)"},
+		{"fat_g", "NUMERIC(8,2)"},
+	}
+	for _, col := range nutritionColumns {
+		if err := addColumnIfMissing(tx, "recipes", col.name, col.definition); err != nil {
+			return err
+		}
+	}
+
+	if err := addColumnIfMissing(tx, "recipes", "deleted_at", "TIMESTAMPTZ"); err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(tx, "recipes", "servings", "INT NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(tx, "recipes", "difficulty",
+		"TEXT NOT NULL DEFAULT 'medium' CHECK (difficulty IN ('easy', 'medium', 'hard'))"); err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(tx, "users", "email_verified", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(tx, "users", "is_admin", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(tx, "users", "updated_at", "TIMESTAMPTZ NOT NULL DEFAULT NOW()"); err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(tx, "comments", "parent_id", "INT REFERENCES comments(id) ON DELETE CASCADE"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func createIndexes(tx *sql.Tx) error {
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_users_username ON users(username)`,
+		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
+		`CREATE INDEX IF NOT EXISTS idx_recipes_name ON recipes(name)`,
+		`CREATE INDEX IF NOT EXISTS idx_ratings_recipe ON ratings(recipe_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_ratings_user ON ratings(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_recipe ON comments(recipe_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_user ON comments(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_parent ON comments(parent_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_favorites_recipe ON favorites(recipe_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_comment_reports_comment ON comment_reports(comment_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_recipe_ingredients_ingredient ON recipe_ingredients(ingredient_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_ingredients_name_lower ON ingredients(LOWER(name))`,
+	}
+	for _, q := range indexes {
+		if err := execOptional(tx, q); err != nil {
+			log.Printf("Warning: could not create index: %v", err)
+		}
+	}
+	return nil
+}
+
+func addRatingsCooked(tx *sql.Tx) error {
+	_, err := tx.Exec(`ALTER TABLE ratings ADD COLUMN cooked BOOLEAN NOT NULL DEFAULT FALSE`)
+	return err
+}
+
+// indexRecipeNamesPerUser backs the case-insensitive duplicate name check on create.
+func indexRecipeNamesPerUser(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_recipes_user_name_lower ON recipes(user_id, LOWER(name))`)
+	return err
+}
+
+// createRatingCache adds the per-recipe rating aggregates read by GetRatingStats.
+// The table starts empty; the rating cache refresher fills it.
+func createRatingCache(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS recipe_rating_cache (
+		recipe_id INT PRIMARY KEY REFERENCES recipes(id) ON DELETE CASCADE,
+		average_rating DOUBLE PRECISION NOT NULL,
+		total_ratings INT NOT NULL,
+		cooked_count INT NOT NULL,
+		breakdown JSONB NOT NULL DEFAULT '{}',
+		refreshed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`)
+	return err
+}
+
+// addRecipesUpdatedAt records when a recipe's content last changed. Existing rows
+// start at their creation time.
+func addRecipesUpdatedAt(tx *sql.Tx) error {
+	if _, err := tx.Exec(`ALTER TABLE recipes ADD COLUMN updated_at TIMESTAMPTZ`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE recipes SET updated_at = created_at`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`ALTER TABLE recipes ALTER COLUMN updated_at SET DEFAULT NOW(), ALTER COLUMN updated_at SET NOT NULL`)
+	return err
+}
+
+// addRecipesViewCount adds the counter the recipe view counter increments.
+func addRecipesViewCount(tx *sql.Tx) error {
+	_, err := tx.Exec(`ALTER TABLE recipes ADD COLUMN view_count BIGINT NOT NULL DEFAULT 0`)
+	return err
+}
+
+// createRevokedTokens adds the access token denylist checked on every authenticated
+// request. Rows are only needed until the token would have expired.
+func createRevokedTokens(tx *sql.Tx) error {
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti TEXT PRIMARY KEY,
+		expires_at TIMESTAMPTZ NOT NULL,
+		revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens(expires_at)`)
+	return err
+}
+
+// addRecipeSteps stores structured instructions as a JSON array of strings, on
+// recipes and on their saved versions so reverting restores them.
+func addRecipeSteps(tx *sql.Tx) error {
+	if _, err := tx.Exec(`ALTER TABLE recipes ADD COLUMN steps JSONB NOT NULL DEFAULT '[]'`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`ALTER TABLE recipe_versions ADD COLUMN steps JSONB NOT NULL DEFAULT '[]'`)
+	return err
+}
+
+// addRecipesOrphaned marks recipes whose creator deleted their account, so they are
+// not mistaken for legacy recipes (user_id NULL from before ownership tracking) that
+// any user may edit. Recipes orphaned before this migration can't be told apart.
+func addRecipesOrphaned(tx *sql.Tx) error {
+	_, err := tx.Exec(`ALTER TABLE recipes ADD COLUMN orphaned BOOLEAN NOT NULL DEFAULT FALSE`)
+	return err
+}
+
+func addPasswordColumnIfMissing(tx *sql.Tx) error {
+	var exists bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns 
+			WHERE table_name = 'users' AND column_name = 'password'
+		)
+	`).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		if _, err := tx.Exec(`ALTER TABLE users ADD COLUMN password TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("add password column: %w", err)
+		}
+		log.Println("✓ Password column added to users table")
+	}
+
+	return nil
+}
+
+func addRecipeUserIDIfMissing(tx *sql.Tx) error {
+	var exists bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_name = 'recipes' AND column_name = 'user_id'
+		)
+	`).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := tx.Exec(`ALTER TABLE recipes ADD COLUMN user_id INT REFERENCES users(id) ON DELETE SET NULL`); err != nil {
+			return fmt.Errorf("add recipes.user_id column: %w", err)
+		}
+		log.Println("✓ recipes.user_id column added")
+	}
+	return nil
+}
+
+// addColumnIfMissing adds table.column with the given SQL definition when it doesn't exist yet.
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	var exists bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_name = $1 AND column_name = $2
+		)
+	`, table, column).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+			return fmt.Errorf("add %s.%s column: %w", table, column, err)
+		}
+		log.Printf("✓ %s.%s column added", table, column)
+	}
+	return nil
+}
+
+func addUniqueConstraintsIfMissing(tx *sql.Tx) error {
+	var usernameUnique bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM pg_constraint 
+			WHERE conname = 'users_username_key'
+		)
+	`).Scan(&usernameUnique)
+	if err == nil && !usernameUnique {
+		if err := execOptional(tx, `ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username)`); err != nil {
+			log.Printf("Warning: could not add unique constraint on username: %v", err)
+		}
+	}
+
+	var emailUnique bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM pg_constraint 
+			WHERE conname = 'users_email_key'
+		)
+	`).Scan(&emailUnique)
+	if err == nil && !emailUnique {
+		if err := execOptional(tx, `ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email)`); err != nil {
+			log.Printf("Warning: could not add unique constraint on email: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -3,39 +3,61 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"cooking-app/internal/auth"
+	"cooking-app/internal/mailer"
+	"cooking-app/internal/middleware"
 	"cooking-app/internal/models"
 	"cooking-app/internal/repository"
 )
 
+const (
+	// passwordResetTTL is how long a password reset token stays valid.
+	passwordResetTTL = time.Hour
+	// emailVerificationTTL is how long an email verification token stays valid.
+	emailVerificationTTL = 48 * time.Hour
+)
+
 // AuthHandler handles authentication endpoints.
 type AuthHandler struct {
-	userRepo    *repository.UserRepository
+	userRepo    UserStore
 	authService *auth.Service
+	mailer      mailer.Mailer // delivers reset and verification tokens
 }
 
-// NewAuthHandler creates a new auth handler.
-func NewAuthHandler(userRepo *repository.UserRepository, authService *auth.Service) *AuthHandler {
+// NewAuthHandler creates a new auth handler. Emails are discarded until SetMailer is called.
+func NewAuthHandler(userRepo UserStore, authService *auth.Service) *AuthHandler {
 	return &AuthHandler{
 		userRepo:    userRepo,
 		authService: authService,
+		mailer:      mailer.NopMailer{},
 	}
 }
 
+// SetMailer sets how password reset and verification emails are sent.
+func (h *AuthHandler) SetMailer(m mailer.Mailer) {
+	h.mailer = m
+}
+
 // Register handles user registration.
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req models.RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyError(w, err, "Invalid request body")
 		return
 	}
 
-	// Validate input
-	if req.Username == "" || req.Email == "" || req.Password == "" {
-		http.Error(w, "Username, email, and password are required", http.StatusBadRequest)
+	// Validate input, reporting every bad field at once
+	if fields := h.authService.ValidateRegistration(req.Username, req.Email, req.Password); fields != nil {
+		writeValidationErrors(w, fields)
 		return
 	}
 
@@ -43,10 +65,10 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	hashedPassword, err := h.authService.HashPassword(req.Password)
 	if err != nil {
 		if errors.Is(err, auth.ErrWeakPassword) {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeJSONError(w, http.StatusBadRequest, err.Error())
 			return
 		}
-		http.Error(w, "Failed to process password", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to process password")
 		return
 	}
 
@@ -60,90 +82,434 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	)
 	if err != nil {
 		if errors.Is(err, repository.ErrUsernameExists) {
-			http.Error(w, "Username already exists", http.StatusConflict)
+			writeJSONError(w, http.StatusConflict, "Username already exists")
 			return
 		}
 		if errors.Is(err, repository.ErrEmailExists) {
-			http.Error(w, "Email already exists", http.StatusConflict)
+			writeJSONError(w, http.StatusConflict, "Email already exists")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
+	// Issue an email verification token; registration succeeds even if this fails.
+	h.sendVerificationToken(user)
+
+	// Generate tokens and return response
+	h.writeAuthResponse(w, http.StatusCreated, user)
+}
+
+// Login handles user login.
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if req.Username == "" || req.Password == "" {
+		writeJSONError(w, http.StatusBadRequest, "Username and password are required")
+		return
+	}
+
+	// The identifier may be a username or an email address
+	user, err := h.authService.Authenticate(req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			writeJSONError(w, http.StatusUnauthorized, "Invalid credentials")
 			return
 		}
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		var locked *auth.AccountLockedError
+		if errors.As(err, &locked) {
+			writeAccountLocked(w, locked)
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to find user")
 		return
 	}
 
-	// Generate token
+	// Generate tokens and return response
+	h.writeAuthResponse(w, http.StatusOK, user)
+}
+
+// accountLockedResponse is the 429 body for a login attempt on a locked account.
+type accountLockedResponse struct {
+	errorResponse
+	RetryAfterSeconds int `json:"retry_after_seconds"`
+}
+
+func writeAccountLocked(w http.ResponseWriter, locked *auth.AccountLockedError) {
+	seconds := int(math.Ceil(locked.RetryAfter.Seconds()))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(accountLockedResponse{
+		errorResponse: errorResponse{
+			Error:   http.StatusText(http.StatusTooManyRequests),
+			Message: fmt.Sprintf("Too many failed logins; try again in %d minute(s)", int(math.Ceil(locked.RetryAfter.Minutes()))),
+		},
+		RetryAfterSeconds: seconds,
+	})
+}
+
+// writeAuthResponse issues an access token and a new refresh token for user.
+func (h *AuthHandler) writeAuthResponse(w http.ResponseWriter, status int, user *models.User) {
 	token, err := h.authService.GenerateToken(user)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	refreshToken, refreshHash, expiresAt, err := h.authService.NewRefreshToken()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to generate refresh token")
+		return
+	}
+	if err := h.userRepo.CreateRefreshToken(user.ID, refreshHash, expiresAt); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to store refresh token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.authService.AccessTokenTTL().Seconds()),
+		User:         user,
+	})
+}
+
+// ForgotPassword - POST /api/auth/forgot-password
+// Always responds 200 so the endpoint can't be used to discover registered emails.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+
+	if req.Email == "" {
+		writeJSONError(w, http.StatusBadRequest, "Email is required")
 		return
 	}
 
-	// Return response
+	user, err := h.userRepo.GetByEmail(req.Email)
+	if err == nil {
+		token, err := auth.GenerateRandomString(32)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to generate reset token")
+			return
+		}
+		if err := h.userRepo.CreatePasswordResetToken(user.ID, auth.HashToken(token), time.Now().Add(passwordResetTTL)); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to create reset token")
+			return
+		}
+		err = h.mailer.Send(mailer.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body:    fmt.Sprintf("Use this token to reset your password. It expires in %.0f hour(s).\n\n%s", passwordResetTTL.Hours(), token),
+		})
+		if err != nil {
+			// Still answer 200 below: a failure must not reveal that the email is registered.
+			log.Printf("Warning: could not send password reset email to user %d: %v", user.ID, err)
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	err = json.NewEncoder(w).Encode(models.AuthResponse{
-		Token: token,
-		User:  user,
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "If that email is registered, a password reset link has been sent",
 	})
+}
+
+// ResetPassword - POST /api/auth/reset-password
+// Also signs the user out everywhere: all their refresh tokens are revoked.
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+
+	if req.Token == "" || req.NewPassword == "" {
+		writeJSONError(w, http.StatusBadRequest, "Token and new_password are required")
+		return
+	}
+
+	hashedPassword, err := h.authService.HashPassword(req.NewPassword)
 	if err != nil {
+		if errors.Is(err, auth.ErrWeakPassword) {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
+
+	if err := h.userRepo.ResetPassword(auth.HashToken(req.Token), hashedPassword); err != nil {
+		if errors.Is(err, repository.ErrInvalidResetToken) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid or expired reset token")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to reset password")
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Password has been reset"})
 }
 
-// Login handles user login.
-func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var req models.LoginRequest
+// ChangePassword - PUT /api/auth/password (protected)
+// Revokes all of the user's refresh tokens, including the caller's.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ChangePasswordRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyError(w, err, "Invalid request body")
 		return
 	}
 
-	// Validate input
-	if req.Username == "" || req.Password == "" {
-		http.Error(w, "Username and password are required", http.StatusBadRequest)
+	if req.OldPassword == "" || req.NewPassword == "" {
+		writeJSONError(w, http.StatusBadRequest, "old_password and new_password are required")
+		return
+	}
+
+	userID := middleware.MustGetUserID(r)
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			writeJSONError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to find user")
+		return
+	}
+
+	if err := h.authService.ComparePassword(user.Password, req.OldPassword); err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "Current password is incorrect")
 		return
 	}
 
-	// Try to find user by username or email
-	var user *models.User
-	var err error
+	hashedPassword, err := h.authService.HashPassword(req.NewPassword)
+	if err != nil {
+		if errors.Is(err, auth.ErrWeakPassword) {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
 
-	// Check if it's an email (contains @)
-	if strings.Contains(req.Username, "@") {
-		user, err = h.userRepo.GetByEmail(req.Username)
-	} else {
-		user, err = h.userRepo.GetByUsername(req.Username)
+	if err := h.userRepo.UpdatePassword(userID, hashedPassword); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to update password")
+		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Password updated"})
+}
+
+// DeleteAccount - DELETE /api/profile/me (protected)
+// Permanently deletes the authenticated user's account after re-checking their
+// password, and returns a summary of the data removed with it.
+func (h *AuthHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	var req models.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+	if req.Password == "" {
+		writeJSONError(w, http.StatusBadRequest, "password is required to delete your account")
+		return
+	}
+
+	userID := middleware.MustGetUserID(r)
+	user, err := h.userRepo.GetByID(userID)
 	if err != nil {
-		if err == repository.ErrUserNotFound {
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			writeJSONError(w, http.StatusNotFound, "User not found")
 			return
 		}
-		http.Error(w, "Failed to find user", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to find user")
 		return
 	}
 
-	// Compare password
 	if err := h.authService.ComparePassword(user.Password, req.Password); err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Password is incorrect")
 		return
 	}
 
-	// Generate token
-	token, err := h.authService.GenerateToken(user)
+	summary, err := h.userRepo.DeleteAccount(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			writeJSONError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Account deleted",
+		"deleted": summary,
+	})
+}
+
+// sendVerificationToken creates an email verification token for user and mails it.
+func (h *AuthHandler) sendVerificationToken(user *models.User) {
+	token, err := auth.GenerateRandomString(32)
+	if err != nil {
+		log.Printf("Warning: could not generate verification token for user %d: %v", user.ID, err)
+		return
+	}
+	if err := h.userRepo.CreateEmailVerificationToken(user.ID, auth.HashToken(token), time.Now().Add(emailVerificationTTL)); err != nil {
+		log.Printf("Warning: could not store verification token for user %d: %v", user.ID, err)
+		return
+	}
+	err = h.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body:    fmt.Sprintf("Use this token to verify your email address. It expires in %.0f hour(s).\n\n%s", emailVerificationTTL.Hours(), token),
+	})
+	if err != nil {
+		log.Printf("Warning: could not send verification email to user %d: %v", user.ID, err)
+	}
+}
+
+// VerifyEmail - POST /api/auth/verify-email
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+
+	if req.Token == "" {
+		writeJSONError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	user, err := h.userRepo.VerifyEmail(auth.HashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidVerifyToken) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid or expired verification token")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// ResendVerification - POST /api/auth/resend-verification (protected)
+// Mails the authenticated user a new verification token, e.g. for accounts created
+// before verification existed or whose token expired. Earlier tokens stay valid.
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	user, err := h.userRepo.GetByID(middleware.MustGetUserID(r))
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if user.EmailVerified {
+		writeJSONError(w, http.StatusConflict, "Email is already verified")
 		return
 	}
 
-	// Return response
+	h.sendVerificationToken(user)
+
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(models.AuthResponse{
-		Token: token,
-		User:  user,
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "A new verification email has been sent",
 	})
+}
+
+// Refresh - POST /api/auth/refresh
+// Mints a new access token for a valid refresh token. The refresh token itself is unchanged.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+
+	if req.RefreshToken == "" {
+		writeJSONError(w, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	user, err := h.userRepo.GetUserByRefreshToken(auth.HashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidRefreshToken) || errors.Is(err, repository.ErrUserNotFound) {
+			writeJSONError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to validate refresh token")
+		return
+	}
+
+	token, err := h.authService.GenerateToken(user)
 	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.AuthResponse{
+		Token:     token,
+		ExpiresIn: int(h.authService.AccessTokenTTL().Seconds()),
+		User:      user,
+	})
+}
+
+// Logout - POST /api/auth/logout
+// Revokes the access token in the Authorization header, so it is rejected from now
+// on, and/or the refresh token in the body, so it can no longer mint access tokens.
+// At least one of the two is required; the body may be omitted.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeBodyError(w, err, "Invalid request body")
 		return
 	}
+
+	accessToken, hasAccessToken := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !hasAccessToken && req.RefreshToken == "" {
+		writeJSONError(w, http.StatusBadRequest, "A bearer token or refresh_token is required")
+		return
+	}
+
+	if hasAccessToken {
+		switch err := h.authService.RevokeToken(accessToken); {
+		case err == nil, errors.Is(err, auth.ErrTokenExpired):
+			// An expired token is already unusable.
+		case errors.Is(err, auth.ErrTokenMalformed), errors.Is(err, auth.ErrTokenInvalid),
+			errors.Is(err, auth.ErrTokenWrongIssuer), errors.Is(err, auth.ErrTokenMissingExpiry),
+			errors.Is(err, auth.ErrTokenMissingIssuer):
+			writeJSONError(w, http.StatusUnauthorized, "Invalid access token")
+			return
+		default:
+			writeJSONError(w, http.StatusInternalServerError, "Failed to revoke access token")
+			return
+		}
+	}
+
+	if req.RefreshToken != "" {
+		if err := h.userRepo.RevokeRefreshToken(auth.HashToken(req.RefreshToken)); err != nil {
+			if !errors.Is(err, repository.ErrInvalidRefreshToken) {
+				writeJSONError(w, http.StatusInternalServerError, "Failed to revoke refresh token")
+				return
+			}
+			// Already revoked or unknown: logging out is idempotent.
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
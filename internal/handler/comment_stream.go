@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cooking-app/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// commentStreamHeartbeat is how often an idle comment stream sends a comment line,
+// keeping proxies from timing it out and noticing clients that have gone away.
+const commentStreamHeartbeat = 30 * time.Second
+
+// SetCommentBroker enables StreamComments, fed by the broker the rating repository publishes to.
+func (h *RatingHandler) SetCommentBroker(b *repository.CommentBroker) {
+	h.broker = b
+}
+
+// StreamComments - GET /api/recipes/{id}/comments/stream
+// Server-sent events: each comment posted on the recipe after the client connects
+// arrives as a "comment" event whose data is the comment JSON. Earlier comments
+// come from GET /comments.
+func (h *RatingHandler) StreamComments(w http.ResponseWriter, r *http.Request) {
+	recipeID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok || h.broker == nil {
+		writeJSONError(w, http.StatusInternalServerError, "Streaming unsupported")
+		return
+	}
+	if !h.requireRecipe(w, recipeID) {
+		return
+	}
+
+	comments, cancel := h.broker.Subscribe(recipeID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(commentStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case c, ok := <-comments:
+			if !ok {
+				return // broker closed: the server is shutting down
+			}
+			data, err := json.Marshal(c)
+			if err != nil {
+				h.logger.LogFromRequest(r, "json_encode_error", 0)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: comment\ndata: %s\n\n", c.ID, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
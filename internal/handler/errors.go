@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// errorResponse is the JSON body of every API error.
+type errorResponse struct {
+	Error   string `json:"error"`   // HTTP status text, e.g. "Not Found"
+	Message string `json:"message"` // human-readable detail
+}
+
+// writeJSONError writes an error response as JSON with the given status.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: http.StatusText(status), Message: message})
+}
+
+// decodeStrict decodes the JSON request body into v, failing on fields v doesn't
+// have so that misspelled fields are reported instead of silently dropped.
+func decodeStrict(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// writeBodyError reports a request body that couldn't be decoded: 413 when it was
+// cut off by the body size limit, 400 naming the field when decodeStrict found an
+// unknown one, otherwise 400 with message.
+func writeBodyError(w http.ResponseWriter, err error, message string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	// encoding/json has no typed error for unknown fields.
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		writeJSONError(w, http.StatusBadRequest, "Unknown field "+field)
+		return
+	}
+	writeJSONError(w, http.StatusBadRequest, message)
+}
+
+// validationErrorResponse is the 400 body for requests with invalid fields.
+type validationErrorResponse struct {
+	errorResponse
+	Errors map[string]string `json:"errors"` // JSON field name -> problem
+}
+
+// writeValidationErrors writes a 400 listing every invalid field.
+func writeValidationErrors(w http.ResponseWriter, fields map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(validationErrorResponse{
+		errorResponse: errorResponse{Error: http.StatusText(http.StatusBadRequest), Message: "Validation failed"},
+		Errors:        fields,
+	})
+}
+
+// probeMethods are tried when working out which methods a path supports.
+var probeMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// Unrouted returns the router's fallback handler for requests no route accepted.
+// If the path exists under other methods it answers 405 with an Allow header,
+// otherwise 404, both as JSON. OPTIONS requests (CORS preflight) are answered by
+// cors instead. Install it as both NotFoundHandler and MethodNotAllowedHandler:
+// mux reports many method mismatches under subrouters as plain not-found.
+func Unrouted(router *mux.Router, cors func(http.Handler) http.Handler) http.Handler {
+	fallback := cors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if w.Header().Get("Allow") == "" {
+			writeJSONError(w, http.StatusNotFound, "No route for "+r.URL.Path)
+			return
+		}
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method "+r.Method+" is not allowed on "+r.URL.Path)
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, method := range probeMethods {
+			if method == r.Method {
+				continue
+			}
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			var match mux.RouteMatch
+			if router.Match(probe, &match) && match.MatchErr == nil {
+				allowed = append(allowed, method)
+			}
+		}
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+		}
+		fallback.ServeHTTP(w, r)
+	})
+}
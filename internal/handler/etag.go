@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cooking-app/internal/models"
+)
+
+// writeJSONWithETag encodes v, tags it with an ETag derived from version and answers
+// 304 Not Modified instead when the request's If-None-Match already names it. version
+// must change whenever a client should refetch, and may leave out fields that change
+// on every request, such as view_count. The tag is weak because the body it stands
+// for is not byte-for-byte identical across those changes.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, version string, v interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+	sum := sha256.Sum256([]byte(version))
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Add("Vary", "Authorization")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+}
+
+// recipeDetailVersion is the ETag version of a GetRecipe response: the recipe's last
+// content change, the requested servings, the rating stats and the caller's own rating
+// and favorite. view_count is left out so counting a view doesn't invalidate the tag.
+func recipeDetailVersion(d models.RecipeDetail) string {
+	myRating, isFavorite := "-", "-"
+	if d.MyRating != nil {
+		myRating = fmt.Sprint(*d.MyRating)
+	}
+	if d.IsFavorite != nil {
+		isFavorite = fmt.Sprint(*d.IsFavorite)
+	}
+	return fmt.Sprintf("recipe:%d|updated:%d|servings:%d|rating:%g/%d|mine:%s|favorite:%s",
+		d.ID, d.UpdatedAt.UnixNano(), d.Servings, d.AverageRating, d.TotalRatings, myRating, isFavorite)
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag, using the
+// weak comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
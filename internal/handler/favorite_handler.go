@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"cooking-app/internal/logger"
+	"cooking-app/internal/middleware"
+	"cooking-app/internal/models"
+	"cooking-app/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// FavoriteHandler handles recipe bookmark endpoints.
+type FavoriteHandler struct {
+	repo       *repository.FavoriteRepository
+	recipeRepo *repository.RecipeRepository
+	logger     *logger.ActivityLogger
+}
+
+// NewFavoriteHandler creates a new favorites handler.
+func NewFavoriteHandler(repo *repository.FavoriteRepository, recipeRepo *repository.RecipeRepository, log *logger.ActivityLogger) *FavoriteHandler {
+	return &FavoriteHandler{
+		repo:       repo,
+		recipeRepo: recipeRepo,
+		logger:     log,
+	}
+}
+
+// AddFavorite - POST /api/recipes/{id}/favorite
+func (h *FavoriteHandler) AddFavorite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	recipeID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	if _, err := h.recipeRepo.GetByID(recipeID); err != nil {
+		writeJSONError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+
+	userID := middleware.MustGetUserID(r)
+	fav, created, err := h.repo.Add(userID, recipeID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save favorite")
+		return
+	}
+
+	h.logger.LogFromRequest(r, "recipe_favorited", recipeID)
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(fav)
+}
+
+// RemoveFavorite - DELETE /api/recipes/{id}/favorite
+func (h *FavoriteHandler) RemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	recipeID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	userID := middleware.MustGetUserID(r)
+	if err := h.repo.Remove(userID, recipeID); err != nil {
+		if errors.Is(err, repository.ErrFavoriteNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Favorite not found")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to remove favorite")
+		return
+	}
+
+	h.logger.LogFromRequest(r, "recipe_unfavorited", recipeID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFavorites - GET /api/profile/favorites
+func (h *FavoriteHandler) ListFavorites(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.MustGetUserID(r)
+	favorites, err := h.repo.ListByUser(userID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch favorites")
+		return
+	}
+
+	ids := make([]int, len(favorites))
+	for i, fav := range favorites {
+		ids[i] = fav.RecipeID
+	}
+	recipes, err := h.recipeRepo.GetByIDs(ids)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch favorites")
+		return
+	}
+	byID := make(map[int]*models.Recipe, len(recipes))
+	for _, rec := range recipes {
+		byID[rec.ID] = rec
+	}
+
+	// Favorites of recipes deleted since they were saved are left out.
+	list := make([]*models.UserFavorite, 0, len(favorites))
+	for _, fav := range favorites {
+		if rec, ok := byID[fav.RecipeID]; ok {
+			fav.Recipe = rec
+			list = append(list, fav)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cooking-app/internal/logger"
+	"cooking-app/internal/models"
+	"cooking-app/internal/recipe"
+	"cooking-app/internal/repository"
+)
+
+// IngredientHandler handles ingredient catalogue endpoints.
+type IngredientHandler struct {
+	repo           *repository.IngredientRepository
+	enhancedSearch *recipe.EnhancedSearchService // source of ingredient synonyms
+	logger         *logger.ActivityLogger
+}
+
+// NewIngredientHandler creates a new ingredient handler.
+func NewIngredientHandler(repo *repository.IngredientRepository, enhancedSearch *recipe.EnhancedSearchService, log *logger.ActivityLogger) *IngredientHandler {
+	return &IngredientHandler{
+		repo:           repo,
+		enhancedSearch: enhancedSearch,
+		logger:         log,
+	}
+}
+
+// SearchIngredients - GET /api/ingredients/search?q=on&limit=10 (at most 50). Returns stored
+// ingredients and known synonyms whose name starts with q, deduplicated by name.
+func (h *IngredientHandler) SearchIngredients(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+
+	limit, _, _, err := parsePagination(r, 10)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit = min(limit, 50) // suggestions, not a page: keep the list short
+
+	suggestions := []models.IngredientSuggestion{}
+	if q != "" {
+		ingredients, err := h.repo.SearchByPrefix(q, limit)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to search ingredients")
+			return
+		}
+
+		seen := make(map[string]bool)
+		for _, ing := range ingredients {
+			seen[strings.ToLower(ing.Name)] = true
+			suggestions = append(suggestions, models.IngredientSuggestion{ID: ing.ID, Name: ing.Name})
+		}
+		for _, name := range h.enhancedSearch.IngredientSynonymsWithPrefix(q) {
+			if len(suggestions) >= limit {
+				break
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			suggestions = append(suggestions, models.IngredientSuggestion{Name: name, Synonym: true})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestions)
+}
+
+// NormalizeIngredients - POST /api/ingredients/normalize with {"names": [...]}.
+// Previews the canonical name the matcher uses for each input, so clients can
+// offer typo corrections before saving a recipe. Results keep the input order.
+func (h *IngredientHandler) NormalizeIngredients(w http.ResponseWriter, r *http.Request) {
+	var req models.NormalizeIngredientsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+	if len(req.Names) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "names is required")
+		return
+	}
+	if len(req.Names) > models.MaxNormalizeIngredients {
+		writeJSONError(w, http.StatusBadRequest, "at most "+strconv.Itoa(models.MaxNormalizeIngredients)+" names can be normalized at once")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.enhancedSearch.NormalizeIngredients(req.Names))
+}
+
+// CreateIngredient - POST /api/ingredients. Returns 201 for a new ingredient and
+// 200 when one with the same (normalized) name already exists.
+func (h *IngredientHandler) CreateIngredient(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateIngredientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+
+	name := repository.NormalizeIngredientName(req.Name)
+	if name == "" {
+		writeJSONError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	ing, created, err := h.repo.GetOrCreate(name)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create ingredient")
+		return
+	}
+
+	if created {
+		h.logger.LogFromRequest(r, "ingredient_created", ing.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(ing)
+}
+
+// GetPopularIngredients - GET /api/ingredients/popular?limit=20. The most-used
+// ingredients across all recipes with their usage counts.
+func (h *IngredientHandler) GetPopularIngredients(w http.ResponseWriter, r *http.Request) {
+	limit, _, _, err := parsePagination(r, defaultPageLimit)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	usage, err := h.repo.GetPopular(limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch popular ingredients")
+		return
+	}
+	if usage == nil {
+		usage = []models.IngredientUsage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+// MergeIngredients - POST /api/admin/ingredients/merge (admin) with {"from_id", "to_id"}.
+// Folds a misspelled or duplicate ingredient into the correct one and deletes it.
+func (h *IngredientHandler) MergeIngredients(w http.ResponseWriter, r *http.Request) {
+	var req models.MergeIngredientsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+	if req.FromID <= 0 || req.ToID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "from_id and to_id are required")
+		return
+	}
+	if req.FromID == req.ToID {
+		writeJSONError(w, http.StatusBadRequest, "from_id and to_id must differ")
+		return
+	}
+
+	result, err := h.repo.MergeIngredients(req.FromID, req.ToID)
+	if err != nil {
+		if errors.Is(err, repository.ErrIngredientNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Ingredient not found")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to merge ingredients")
+		return
+	}
+	for _, id := range result.RecipeIDs {
+		h.enhancedSearch.NotifyRecipeChange(id)
+	}
+	h.logger.LogFromRequest(r, "ingredients_merged", result.Into.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// clampLimit bounds a requested page size to 1..maxPageLimit, using def for
+// zero or negative values.
+func clampLimit(n, def int) int {
+	if n <= 0 {
+		return def
+	}
+	if n > maxPageLimit {
+		return maxPageLimit
+	}
+	return n
+}
+
+// parseNonNegativeInt reads an optional non-negative integer query parameter.
+// present is false when the parameter is absent; invalid values return an error
+// suitable for a 400 response.
+func parseNonNegativeInt(r *http.Request, name string) (n int, present bool, err error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, false, nil
+	}
+	n, err = strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, true, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return n, true, nil
+}
+
+// parsePagination reads ?limit= and ?offset= from the query. Missing values use
+// defaultLimit and 0; limit is clamped with clampLimit and a negative offset
+// becomes 0. Non-numeric values return an error suitable for a 400 response.
+// present reports whether either parameter was given.
+func parsePagination(r *http.Request, defaultLimit int) (limit, offset int, present bool, err error) {
+	query := r.URL.Query()
+	limit = defaultLimit
+	if v := query.Get("limit"); v != "" {
+		present = true
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, 0, present, fmt.Errorf("limit must be an integer")
+		}
+		limit = clampLimit(n, defaultLimit)
+	}
+	if v := query.Get("offset"); v != "" {
+		present = true
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, 0, present, fmt.Errorf("offset must be an integer")
+		}
+		if n > 0 {
+			offset = n
+		}
+	}
+	return limit, offset, present, nil
+}
+
+// setPaginationHeaders describes one page of a list for clients that page through
+// headers rather than the body: X-Total-Count holds total, and an RFC 5988 Link
+// header points at the next and previous pages when they exist. Links reuse r's
+// path and query with limit and offset replaced.
+func setPaginationHeaders(w http.ResponseWriter, r *http.Request, total, limit, offset int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if limit <= 0 {
+		return
+	}
+
+	pageURL := func(off int) string {
+		u := *r.URL
+		q := u.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		u.RawQuery = q.Encode()
+		return u.RequestURI()
+	}
+	var links []string
+	if offset+limit < total {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(offset+limit)))
+	}
+	if offset > 0 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(max(offset-limit, 0))))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
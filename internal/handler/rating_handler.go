@@ -5,6 +5,9 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"cooking-app/internal/logger"
 	"cooking-app/internal/middleware"
@@ -14,70 +17,201 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// defaultMaxCommentLength is used until SetMaxCommentLength is called.
+const defaultMaxCommentLength = 2000
+
+// RatingStore is the rating and comment storage RatingHandler needs;
+// *repository.RatingRepository implements it.
+type RatingStore interface {
+	CreateOrUpdateRating(recipeID, userID, rating int, cooked bool) (*models.Rating, error)
+	GetRatingsByRecipe(recipeID int) ([]*models.Rating, error)
+	GetRatingsByUser(userID, limit, offset int) ([]*models.UserRating, error)
+	CountRatingsByUser(userID int) (int, error)
+	GetUserRatingForRecipe(recipeID, userID int) (*models.Rating, error)
+	DeleteRating(recipeID, userID int) error
+	GetRatingStats(recipeID int) (*models.RatingStats, error)
+	CreateComment(recipeID, userID int, content string, parentID *int) (*models.Comment, error)
+	GetCommentsByRecipe(recipeID, limit, offset int, sort string) ([]*models.Comment, error)
+	CountCommentsByRecipe(recipeID int) (int, error)
+	UpdateComment(id, userID int, content string) (*models.Comment, error)
+	DeleteComment(id, userID int) error
+	ReportComment(commentID, userID int, reason string) (*models.CommentReport, error)
+	GetReportedComments() ([]*models.ReportedComment, error)
+}
+
+// RecipeExistence reports whether a recipe exists; *repository.RecipeRepository
+// implements it.
+type RecipeExistence interface {
+	Exists(id int) (bool, error)
+}
+
 type RatingHandler struct {
-	repo   *repository.RatingRepository
-	logger *logger.ActivityLogger
+	repo             RatingStore
+	recipeRepo       RecipeExistence
+	logger           *logger.ActivityLogger
+	maxCommentLength int
+	broker           *repository.CommentBroker // source for StreamComments; nil disables it
 }
 
-func NewRatingHandler(repo *repository.RatingRepository, log *logger.ActivityLogger) *RatingHandler {
+func NewRatingHandler(repo RatingStore, recipeRepo RecipeExistence, log *logger.ActivityLogger) *RatingHandler {
 	return &RatingHandler{
-		repo:   repo,
-		logger: log,
+		repo:             repo,
+		recipeRepo:       recipeRepo,
+		logger:           log,
+		maxCommentLength: defaultMaxCommentLength,
 	}
 }
 
+// SetMaxCommentLength sets the longest comment, in characters, that may be posted.
+func (h *RatingHandler) SetMaxCommentLength(n int) {
+	if n > 0 {
+		h.maxCommentLength = n
+	}
+}
+
+// cleanComment strips control characters (keeping newlines and tabs) and surrounding
+// whitespace from comment content. It returns the cleaned content, or a client-facing
+// message when the result is empty or longer than the configured maximum.
+func (h *RatingHandler) cleanComment(content string) (string, string) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, content)
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", "Comment content cannot be empty"
+	}
+	if utf8.RuneCountInString(content) > h.maxCommentLength {
+		return "", "Comment must be at most " + strconv.Itoa(h.maxCommentLength) + " characters"
+	}
+	return content, ""
+}
+
+// requireRecipe writes a 404 and returns false unless the recipe exists, so inserts
+// referencing it don't fail on the foreign key with an opaque 500.
+func (h *RatingHandler) requireRecipe(w http.ResponseWriter, recipeID int) bool {
+	exists, err := h.recipeRepo.Exists(recipeID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to look up recipe")
+		return false
+	}
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Recipe not found")
+		return false
+	}
+	return true
+}
+
 func (h *RatingHandler) CreateOrUpdateRating(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	recipeID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
 
 	var req models.CreateRatingRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeStrict(r, &req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
 		return
 	}
 
 	if req.Rating < 1 || req.Rating > 5 {
-		http.Error(w, "Rating must be between 1 and 5", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Rating must be between 1 and 5")
+		return
+	}
+	if !h.requireRecipe(w, recipeID) {
 		return
 	}
 
 	userID := middleware.MustGetUserID(r)
-	rating, err := h.repo.CreateOrUpdateRating(recipeID, userID, req.Rating)
+	rating, err := h.repo.CreateOrUpdateRating(recipeID, userID, req.Rating, req.Cooked)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	h.logger.Log("rating_created_or_updated", recipeID)
+	h.logger.LogFromRequest(r, "rating_created_or_updated", recipeID)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(rating); err != nil {
-		h.logger.Log("json_encode_error", 0)
+		h.logger.LogFromRequest(r, "json_encode_error", 0)
+	}
+}
+
+// DeleteRating - DELETE /api/recipes/{id}/ratings (protected)
+// Retracts the authenticated user's rating of the recipe.
+func (h *RatingHandler) DeleteRating(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	recipeID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	userID := middleware.MustGetUserID(r)
+	if err := h.repo.DeleteRating(recipeID, userID); err != nil {
+		if errors.Is(err, repository.ErrRatingNotFound) {
+			writeJSONError(w, http.StatusNotFound, "You have not rated this recipe")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to delete rating")
+		return
 	}
+
+	h.logger.LogFromRequest(r, "rating_deleted", recipeID)
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *RatingHandler) GetRatingsByRecipe(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	recipeID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
 
 	ratings, err := h.repo.GetRatingsByRecipe(recipeID)
 	if err != nil {
-		http.Error(w, "Failed to fetch ratings", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch ratings")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(ratings); err != nil {
-		h.logger.Log("json_encode_error", 0)
+		h.logger.LogFromRequest(r, "json_encode_error", 0)
+	}
+}
+
+// GetMyRatings - GET /api/profile/ratings?limit=&offset= (protected)
+func (h *RatingHandler) GetMyRatings(w http.ResponseWriter, r *http.Request) {
+	limit, offset, _, err := parsePagination(r, defaultPageLimit)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID := middleware.MustGetUserID(r)
+	ratings, err := h.repo.GetRatingsByUser(userID, limit, offset)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch ratings")
+		return
+	}
+	total, err := h.repo.CountRatingsByUser(userID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch ratings")
+		return
+	}
+
+	setPaginationHeaders(w, r, total, limit, offset)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ratings); err != nil {
+		h.logger.LogFromRequest(r, "json_encode_error", 0)
 	}
 }
 
@@ -85,19 +219,19 @@ func (h *RatingHandler) GetRatingStats(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	recipeID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
 
 	stats, err := h.repo.GetRatingStats(recipeID)
 	if err != nil {
-		http.Error(w, "Failed to fetch rating stats", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch rating stats")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(stats); err != nil {
-		h.logger.Log("json_encode_error", 0)
+		h.logger.LogFromRequest(r, "json_encode_error", 0)
 	}
 }
 
@@ -105,7 +239,7 @@ func (h *RatingHandler) GetUserRatingForRecipe(w http.ResponseWriter, r *http.Re
 	vars := mux.Vars(r)
 	recipeID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
 
@@ -115,17 +249,17 @@ func (h *RatingHandler) GetUserRatingForRecipe(w http.ResponseWriter, r *http.Re
 		if errors.Is(err, repository.ErrRatingNotFound) {
 			w.Header().Set("Content-Type", "application/json")
 			if err := json.NewEncoder(w).Encode(map[string]interface{}{"rating": nil}); err != nil {
-				h.logger.Log("json_encode_error", userID)
+				h.logger.LogFromRequest(r, "json_encode_error", 0)
 			}
 			return
 		}
-		http.Error(w, "Failed to fetch rating", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch rating")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(rating); err != nil {
-		h.logger.Log("json_encode_error", 0)
+		h.logger.LogFromRequest(r, "json_encode_error", 0)
 	}
 }
 
@@ -133,34 +267,47 @@ func (h *RatingHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	recipeID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
 
 	var req models.CreateCommentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeStrict(r, &req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
 		return
 	}
 
-	if req.Content == "" {
-		http.Error(w, "Comment content cannot be empty", http.StatusBadRequest)
+	content, msg := h.cleanComment(req.Content)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	req.Content = content
+	if !h.requireRecipe(w, recipeID) {
 		return
 	}
 
 	userID := middleware.MustGetUserID(r)
-	comment, err := h.repo.CreateComment(recipeID, userID, req.Content)
+	comment, err := h.repo.CreateComment(recipeID, userID, req.Content, req.ParentID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, repository.ErrInvalidParent) {
+			writeJSONError(w, http.StatusBadRequest, "Parent comment must belong to the same recipe")
+			return
+		}
+		if errors.Is(err, repository.ErrCommentRejected) {
+			writeJSONError(w, http.StatusUnprocessableEntity, "Comment was rejected by moderation")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	h.logger.Log("comment_created", recipeID)
+	h.logger.LogFromRequest(r, "comment_created", recipeID)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(comment); err != nil {
-		h.logger.Log("json_encode_error", 0)
+		h.logger.LogFromRequest(r, "json_encode_error", 0)
 	}
 }
 
@@ -168,19 +315,49 @@ func (h *RatingHandler) GetCommentsByRecipe(w http.ResponseWriter, r *http.Reque
 	vars := mux.Vars(r)
 	recipeID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
 
-	comments, err := h.repo.GetCommentsByRecipe(recipeID)
+	limit, offset, _, err := parsePagination(r, defaultPageLimit)
 	if err != nil {
-		http.Error(w, "Failed to fetch comments", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		sort = "newest"
+	}
+	if sort != "newest" && sort != "oldest" {
+		writeJSONError(w, http.StatusBadRequest, "sort must be newest or oldest")
 		return
 	}
 
+	comments, err := h.repo.GetCommentsByRecipe(recipeID, limit, offset, sort)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch comments")
+		return
+	}
+	total, err := h.repo.CountCommentsByRecipe(recipeID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch comments")
+		return
+	}
+	if comments == nil {
+		comments = []*models.Comment{}
+	}
+
+	page := models.CommentPage{
+		Comments:   comments,
+		TotalCount: total,
+		Limit:      limit,
+		Offset:     offset,
+	}
+
+	setPaginationHeaders(w, r, total, limit, offset)
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(comments); err != nil {
-		h.logger.Log("json_encode_error", 0)
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		h.logger.LogFromRequest(r, "json_encode_error", 0)
 	}
 }
 
@@ -188,41 +365,47 @@ func (h *RatingHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	commentID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid comment ID")
 		return
 	}
 
 	var req models.UpdateCommentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeStrict(r, &req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
 		return
 	}
 
-	if req.Content == "" {
-		http.Error(w, "Comment content cannot be empty", http.StatusBadRequest)
+	content, msg := h.cleanComment(req.Content)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
 		return
 	}
+	req.Content = content
 
 	userID := middleware.MustGetUserID(r)
 	comment, err := h.repo.UpdateComment(commentID, userID, req.Content)
 	if err != nil {
 		if errors.Is(err, repository.ErrCommentNotFound) {
-			http.Error(w, "Comment not found", http.StatusNotFound)
+			writeJSONError(w, http.StatusNotFound, "Comment not found")
 			return
 		}
 		if errors.Is(err, repository.ErrCommentForbidden) {
-			http.Error(w, "You can only edit your own comments", http.StatusForbidden)
+			writeJSONError(w, http.StatusForbidden, "You can only edit your own comments")
+			return
+		}
+		if errors.Is(err, repository.ErrCommentRejected) {
+			writeJSONError(w, http.StatusUnprocessableEntity, "Comment was rejected by moderation")
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	h.logger.Log("comment_updated", commentID)
+	h.logger.LogFromRequest(r, "comment_updated", commentID)
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(comment); err != nil {
-		h.logger.Log("json_encode_error", 0)
+		h.logger.LogFromRequest(r, "json_encode_error", 0)
 	}
 }
 
@@ -230,7 +413,7 @@ func (h *RatingHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	commentID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid comment ID")
 		return
 	}
 
@@ -238,18 +421,79 @@ func (h *RatingHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	err = h.repo.DeleteComment(commentID, userID)
 	if err != nil {
 		if errors.Is(err, repository.ErrCommentNotFound) {
-			http.Error(w, "Comment not found", http.StatusNotFound)
+			writeJSONError(w, http.StatusNotFound, "Comment not found")
 			return
 		}
 		if errors.Is(err, repository.ErrCommentForbidden) {
-			http.Error(w, "You can only delete your own comments", http.StatusForbidden)
+			writeJSONError(w, http.StatusForbidden, "You can only delete your own comments")
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	h.logger.Log("comment_deleted", commentID)
+	h.logger.LogFromRequest(r, "comment_deleted", commentID)
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (h *RatingHandler) ReportComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	commentID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	var req models.ReportCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		writeJSONError(w, http.StatusBadRequest, "Report reason cannot be empty")
+		return
+	}
+
+	userID := middleware.MustGetUserID(r)
+	report, err := h.repo.ReportComment(commentID, userID, req.Reason)
+	if err != nil {
+		if errors.Is(err, repository.ErrCommentNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Comment not found")
+			return
+		}
+		if errors.Is(err, repository.ErrAlreadyReported) {
+			writeJSONError(w, http.StatusConflict, "You have already reported this comment")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to report comment")
+		return
+	}
+
+	h.logger.LogFromRequest(r, "comment_reported", commentID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		h.logger.LogFromRequest(r, "json_encode_error", 0)
+	}
+}
+
+// GetReportedComments lists flagged comments for moderators (admin only).
+func (h *RatingHandler) GetReportedComments(w http.ResponseWriter, r *http.Request) {
+	reported, err := h.repo.GetReportedComments()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch reports")
+		return
+	}
+	if reported == nil {
+		reported = []*models.ReportedComment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reported); err != nil {
+		h.logger.LogFromRequest(r, "json_encode_error", 0)
+	}
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cooking-app/internal/middleware"
+	"cooking-app/internal/models"
+)
+
+// recipeCSVHeader is the column order of a CSV export.
+var recipeCSVHeader = []string{
+	"id", "name", "description", "instructions", "prep_time_min", "cook_time_min",
+	"servings", "difficulty", "calories", "protein_g", "carbs_g", "fat_g", "created_at", "ingredients",
+}
+
+// ExportMyRecipes - GET /api/profile/recipes/export?format=json|csv (protected)
+// Streams the authenticated user's recipes as a download. JSON (the default) keeps
+// the full nested structure; CSV has one row per recipe with ingredients joined by "; ".
+func (h *RecipeHandler) ExportMyRecipes(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		writeJSONError(w, http.StatusBadRequest, "format must be json or csv")
+		return
+	}
+	userID := middleware.MustGetUserID(r)
+
+	// Headers are sent with the first recipe, so a query error before that can
+	// still be reported as a 500. Later errors can only cut the download short.
+	started := false
+	start := func() {
+		started = true
+		contentType := "application/json"
+		if format == "csv" {
+			contentType = "text/csv; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="recipes.`+format+`"`)
+	}
+
+	var err error
+	if format == "csv" {
+		cw := csv.NewWriter(w)
+		err = h.repo.StreamByUser(userID, func(rec *models.Recipe) error {
+			if !started {
+				start()
+				cw.Write(recipeCSVHeader)
+			}
+			return cw.Write(recipeCSVRow(rec))
+		})
+		if err == nil && !started {
+			start()
+			cw.Write(recipeCSVHeader)
+		}
+		cw.Flush()
+	} else {
+		enc := json.NewEncoder(w)
+		err = h.repo.StreamByUser(userID, func(rec *models.Recipe) error {
+			sep := ","
+			if !started {
+				start()
+				sep = "["
+			}
+			if _, err := w.Write([]byte(sep)); err != nil {
+				return err
+			}
+			return enc.Encode(rec)
+		})
+		if err == nil {
+			if !started {
+				start()
+				w.Write([]byte("["))
+			}
+			w.Write([]byte("]\n"))
+		}
+	}
+
+	if err != nil && !started {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to export recipes")
+		return
+	}
+	h.logger.LogFromRequest(r, "recipes_exported", 0)
+}
+
+// recipeCSVRow flattens a recipe into recipeCSVHeader columns.
+func recipeCSVRow(rec *models.Recipe) []string {
+	ingredients := make([]string, len(rec.Ingredients))
+	for i, ri := range rec.Ingredients {
+		ingredients[i] = strings.TrimSpace(ri.Quantity + " " + ri.Ingredient.Name)
+	}
+	return []string{
+		strconv.Itoa(rec.ID),
+		rec.Name,
+		rec.Description,
+		rec.Instructions,
+		strconv.Itoa(rec.PrepTimeMin),
+		strconv.Itoa(rec.CookTimeMin),
+		strconv.Itoa(rec.Servings),
+		rec.Difficulty,
+		optionalInt(rec.Calories),
+		optionalFloat(rec.ProteinG),
+		optionalFloat(rec.CarbsG),
+		optionalFloat(rec.FatG),
+		rec.CreatedAt.Format(time.RFC3339),
+		strings.Join(ingredients, "; "),
+	}
+}
+
+func optionalInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func optionalFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -18,100 +19,542 @@ import (
 
 type RecipeHandler struct {
 	repo            *repository.RecipeRepository
+	ratingRepo      *repository.RatingRepository
+	favoriteRepo    *repository.FavoriteRepository
 	search          *recipe.SearchService
 	enhancedSearch  *recipe.EnhancedSearchService
 	logger          *logger.ActivityLogger
+	views           *repository.ViewCounter // counts GetRecipe calls; nil disables counting
 }
 
-func NewRecipeHandler(repo *repository.RecipeRepository, search *recipe.SearchService, enhancedSearch *recipe.EnhancedSearchService, log *logger.ActivityLogger) *RecipeHandler {
+func NewRecipeHandler(repo *repository.RecipeRepository, ratingRepo *repository.RatingRepository, favoriteRepo *repository.FavoriteRepository, search *recipe.SearchService, enhancedSearch *recipe.EnhancedSearchService, log *logger.ActivityLogger) *RecipeHandler {
 	return &RecipeHandler{
 		repo:           repo,
+		ratingRepo:     ratingRepo,
+		favoriteRepo:   favoriteRepo,
 		search:         search,
 		enhancedSearch: enhancedSearch,
 		logger:         log,
 	}
 }
 
-// ListRecipes - GET /api/recipes (optional query: search=..., ingredients=...)
+// SetViewCounter makes GetRecipe record each view with c.
+func (h *RecipeHandler) SetViewCounter(c *repository.ViewCounter) {
+	h.views = c
+}
+
+// ListRecipes - GET /api/recipes (optional query: search=..., mode=indexed, ingredients=..., match=all|any, sort=..., order=asc|desc,
+// difficulty=..., min_total_time=..., max_total_time=... (prep + cook minutes), limit=..., offset=...).
+// Without limit/offset every matching recipe is returned. With explain=true each recipe is
+// wrapped as {"recipe": ..., "matched_fields": [...]} showing which search terms it matched.
 func (h *RecipeHandler) ListRecipes(w http.ResponseWriter, r *http.Request) {
+	limit, offset, paginate, err := parsePagination(r, defaultPageLimit)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	difficulty := r.URL.Query().Get("difficulty")
+	if difficulty != "" && !models.ValidDifficulty(difficulty) {
+		writeJSONError(w, http.StatusBadRequest, "difficulty must be easy, medium or hard")
+		return
+	}
+
+	minTotal, hasMinTotal, err := parseNonNegativeInt(r, "min_total_time")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	maxTotal, hasMaxTotal, err := parseNonNegativeInt(r, "max_total_time")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	match := r.URL.Query().Get("match")
+	if match != "" && match != "all" && match != "any" {
+		writeJSONError(w, http.StatusBadRequest, "match must be all or any")
+		return
+	}
+
 	searchQuery := r.URL.Query().Get("search")
+	mode := r.URL.Query().Get("mode")
 	ingredientsParam := r.URL.Query().Get("ingredients")
+	sortBy := r.URL.Query().Get("sort")
+	order := r.URL.Query().Get("order")
+
+	filter := repository.RecipeListFilter{Difficulty: difficulty, MinTotalTime: -1, MaxTotalTime: -1}
+	if hasMinTotal {
+		filter.MinTotalTime = minTotal
+	}
+	if hasMaxTotal {
+		filter.MaxTotalTime = maxTotal
+	}
 
 	var recipes []*models.Recipe
+	total := -1 // set once the database has filtered and paged the list
 	if ingredientsParam != "" {
 		names := strings.Split(ingredientsParam, ",")
 		for i := range names {
 			names[i] = strings.TrimSpace(names[i])
 		}
-		recipes = h.search.SearchByIngredients(names)
+		recipes = h.search.SearchByIngredients(names, match == "any")
+	} else if searchQuery != "" && mode == "indexed" {
+		// Ranked by number of matching terms, using the in-memory keyword index.
+		// GetByIDs loads every hit in one query and keeps the ranking order.
+		recipes, err = h.repo.GetByIDs(h.enhancedSearch.SearchIndexed(searchQuery))
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to fetch recipes")
+			return
+		}
 	} else if searchQuery != "" {
 		recipes = h.search.SearchByName(searchQuery)
 	} else {
-		recipes = h.repo.GetAll()
+		pageLimit := 0
+		if paginate {
+			pageLimit = limit
+		}
+		recipes, total, err = h.repo.ListFiltered(filter, sortBy, order, pageLimit, offset)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to fetch recipes")
+			return
+		}
 	}
 
-	h.logger.Log("recipes_listed", 0)
+	// Search results are ranked in Go, so they are filtered and paged here.
+	if total < 0 {
+		filtered := []*models.Recipe{}
+		for _, rec := range recipes {
+			if filter.Matches(rec) {
+				filtered = append(filtered, rec)
+			}
+		}
+		recipes = filtered
+		total = len(recipes)
+		if paginate {
+			if offset >= len(recipes) {
+				recipes = []*models.Recipe{}
+			} else {
+				recipes = recipes[offset:min(offset+limit, len(recipes))]
+			}
+		}
+	}
+	if paginate {
+		setPaginationHeaders(w, r, total, limit, offset)
+	}
+
+	h.logger.LogFromRequest(r, "recipes_listed", 0)
 
 	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("explain") == "true" {
+		json.NewEncoder(w).Encode(h.search.Explain(recipes, searchQuery))
+		return
+	}
 	json.NewEncoder(w).Encode(recipes)
 }
 
-// GetRecipe - GET /api/recipes/{id}
+// GetPopularRecipes - GET /api/recipes/popular?limit=10&min_votes=3
+func (h *RecipeHandler) GetPopularRecipes(w http.ResponseWriter, r *http.Request) {
+	limit, _, _, err := parsePagination(r, 10)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	minVotes := 3
+	if v := r.URL.Query().Get("min_votes"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSONError(w, http.StatusBadRequest, "min_votes must be a positive integer")
+			return
+		}
+		minVotes = n
+	}
+
+	popular, err := h.repo.GetPopular(limit, minVotes)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch popular recipes")
+		return
+	}
+	if popular == nil {
+		popular = []*models.PopularRecipe{}
+	}
+
+	h.logger.LogFromRequest(r, "popular_recipes_listed", 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(popular)
+}
+
+// GetRecipe - GET /api/recipes/{id} (optional auth: adds my_rating and is_favorite)
+// Each call counts as a view; a signed-in user's repeat views within 10 minutes are
+// counted once. view_count lags by up to the view counter's flush interval.
+// Responses carry an ETag that ignores view_count; a matching If-None-Match gets 304 Not Modified.
 func (h *RecipeHandler) GetRecipe(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
 
+	servings := 0
+	if v := r.URL.Query().Get("servings"); v != "" {
+		servings, err = strconv.Atoi(v)
+		if err != nil || servings < 1 || servings > 1000 {
+			writeJSONError(w, http.StatusBadRequest, "servings must be between 1 and 1000")
+			return
+		}
+	}
+
 	recipe, err := h.repo.GetByID(id)
 	if err != nil {
-		http.Error(w, "Recipe not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+	if servings > 0 {
+		recipe.ScaleServings(servings)
+	}
+
+	detail := models.RecipeDetail{Recipe: recipe}
+	stats, err := h.ratingRepo.GetRatingStats(id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch rating stats")
 		return
 	}
+	detail.AverageRating = stats.AverageRating
+	detail.TotalRatings = stats.TotalRatings
+
+	userID, authenticated := middleware.GetUserID(r)
+	if h.views != nil {
+		h.views.Record(id, userID)
+	}
+	if authenticated {
+		if rating, err := h.ratingRepo.GetUserRatingForRecipe(id, userID); err == nil {
+			detail.MyRating = &rating.Rating
+		}
+		if isFavorite, err := h.favoriteRepo.IsFavorite(userID, id); err == nil {
+			detail.IsFavorite = &isFavorite
+		}
+	}
 
-	h.logger.Log("recipe_viewed", id)
+	h.logger.LogFromRequest(r, "recipe_viewed", id)
 
+	writeJSONWithETag(w, r, recipeDetailVersion(detail), detail)
+}
+
+// GetRandomRecipe - GET /api/recipes/random (optional query: difficulty=..., max_total_time=...
+// (prep + cook minutes)). Returns one recipe chosen at random, or 404 if none matches.
+func (h *RecipeHandler) GetRandomRecipe(w http.ResponseWriter, r *http.Request) {
+	difficulty := r.URL.Query().Get("difficulty")
+	if difficulty != "" && !models.ValidDifficulty(difficulty) {
+		writeJSONError(w, http.StatusBadRequest, "difficulty must be easy, medium or hard")
+		return
+	}
+	maxTotal, hasMaxTotal, err := parseNonNegativeInt(r, "max_total_time")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !hasMaxTotal {
+		maxTotal = -1
+	}
+
+	recipe, err := h.repo.GetRandom(difficulty, maxTotal)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecipeNotFound) {
+			writeJSONError(w, http.StatusNotFound, "No recipe matches the filters")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to pick a recipe")
+		return
+	}
+
+	h.logger.LogFromRequest(r, "random_recipe_viewed", recipe.ID)
+
+	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(recipe)
 }
 
+// GetRecipesBatch - POST /api/recipes/batch
+// Returns the recipes for up to MaxRecipeBatchIDs IDs in one call, in the order
+// requested. IDs that don't exist or were deleted are simply absent.
+func (h *RecipeHandler) GetRecipesBatch(w http.ResponseWriter, r *http.Request) {
+	var req models.RecipeBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "ids is required")
+		return
+	}
+	if len(req.IDs) > models.MaxRecipeBatchIDs {
+		writeJSONError(w, http.StatusBadRequest, "at most "+strconv.Itoa(models.MaxRecipeBatchIDs)+" ids can be fetched at once")
+		return
+	}
+
+	recipes, err := h.repo.GetByIDs(req.IDs)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch recipes")
+		return
+	}
+	if recipes == nil {
+		recipes = []*models.Recipe{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recipes)
+}
+
+// GetMyRecipes - GET /api/profile/recipes (recipes created by the authenticated user)
+func (h *RecipeHandler) GetMyRecipes(w http.ResponseWriter, r *http.Request) {
+	h.writeUserRecipes(w, r, middleware.MustGetUserID(r))
+}
+
+// GetUserRecipes - GET /api/profile/{id}/recipes (recipes created by another user)
+func (h *RecipeHandler) GetUserRecipes(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	h.writeUserRecipes(w, r, userID)
+}
+
+// writeUserRecipes lists a user's recipes with optional ?limit=&offset= pagination,
+// described by X-Total-Count and Link headers when either is given. Without them
+// every recipe is returned.
+func (h *RecipeHandler) writeUserRecipes(w http.ResponseWriter, r *http.Request, userID int) {
+	limit, offset, paginate, err := parsePagination(r, defaultPageLimit)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !paginate {
+		limit = 0
+	}
+
+	recipes := h.repo.GetByUser(userID, limit, offset)
+	if recipes == nil {
+		recipes = []*models.Recipe{}
+	}
+	if limit > 0 {
+		total, err := h.repo.CountByUser(userID)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to count recipes")
+			return
+		}
+		setPaginationHeaders(w, r, total, limit, offset)
+	}
+
+	h.logger.LogFromRequest(r, "user_recipes_listed", userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recipes)
+}
+
+// GetNutrition - GET /api/recipes/{id}/nutrition
+func (h *RecipeHandler) GetNutrition(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	recipe, err := h.repo.GetByID(id)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.Nutrition{
+		RecipeID: recipe.ID,
+		Calories: recipe.Calories,
+		ProteinG: recipe.ProteinG,
+		CarbsG:   recipe.CarbsG,
+		FatG:     recipe.FatG,
+	})
+}
+
 // CreateRecipe - POST /api/recipes
 func (h *RecipeHandler) CreateRecipe(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateRecipeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeStrict(r, &req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
 		return
 	}
 
-	if req.Name == "" {
-		http.Error(w, "name is required", http.StatusBadRequest)
+	if msg := validateCreateRecipe(&req); msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
 		return
 	}
 
 	userID := middleware.MustGetUserID(r)
-	created := h.repo.Create(&req, userID)
+	created, err := h.repo.Create(&req, userID, r.URL.Query().Get("allow_duplicate") == "true")
+	if err != nil {
+		var invalid *repository.InvalidIngredientsError
+		if errors.As(err, &invalid) {
+			writeJSONError(w, http.StatusBadRequest, invalid.Error())
+			return
+		}
+		var repeated *repository.DuplicateIngredientsError
+		if errors.As(err, &repeated) {
+			writeJSONError(w, http.StatusBadRequest, repeated.Error())
+			return
+		}
+		var duplicate *repository.DuplicateRecipeError
+		if errors.As(err, &duplicate) {
+			writeDuplicateRecipe(w, duplicate)
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create recipe")
+		return
+	}
 	h.search.NotifyRecipeChange(created.ID)
-	h.logger.Log("recipe_created", created.ID)
+	h.enhancedSearch.NotifyRecipeChange(created.ID)
+	h.logger.LogFromRequest(r, "recipe_created", created.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(created)
 }
 
+// duplicateRecipeResponse is the 409 body when the user already has a recipe with that name.
+type duplicateRecipeResponse struct {
+	errorResponse
+	ExistingRecipeID int `json:"existing_recipe_id"`
+}
+
+func writeDuplicateRecipe(w http.ResponseWriter, dup *repository.DuplicateRecipeError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(duplicateRecipeResponse{
+		errorResponse: errorResponse{
+			Error:   http.StatusText(http.StatusConflict),
+			Message: dup.Error() + "; pass ?allow_duplicate=true to create it anyway",
+		},
+		ExistingRecipeID: dup.ExistingID,
+	})
+}
+
+// maxRecipeTimeMin bounds prep_time_min and cook_time_min (about a week).
+const maxRecipeTimeMin = 10000
+
+// validateCreateRecipe returns a client-facing message for an invalid create request, or "".
+// An empty ingredients list is allowed (e.g. for drafts or technique-only recipes).
+func validateCreateRecipe(req *models.CreateRecipeRequest) string {
+	if req.Name == "" {
+		return "name is required"
+	}
+	return validateRecipeFields(req.PrepTimeMin, req.CookTimeMin, req.Servings, req.Difficulty, req.Steps)
+}
+
+// validateRecipeFields checks the fields shared by create and update requests.
+func validateRecipeFields(prepTimeMin, cookTimeMin int, servings *int, difficulty string, steps []string) string {
+	if prepTimeMin < 0 || prepTimeMin > maxRecipeTimeMin {
+		return "prep_time_min must be between 0 and " + strconv.Itoa(maxRecipeTimeMin)
+	}
+	if cookTimeMin < 0 || cookTimeMin > maxRecipeTimeMin {
+		return "cook_time_min must be between 0 and " + strconv.Itoa(maxRecipeTimeMin)
+	}
+	if servings != nil && *servings < 1 {
+		return "servings must be at least 1"
+	}
+	if difficulty != "" && !models.ValidDifficulty(difficulty) {
+		return "difficulty must be easy, medium or hard"
+	}
+	if len(steps) > models.MaxRecipeSteps {
+		return "at most " + strconv.Itoa(models.MaxRecipeSteps) + " steps are allowed"
+	}
+	return ""
+}
+
+// ImportRecipes - POST /api/recipes/import[?allow_duplicate=true] (protected)
+// Body is an array of create requests (at most models.MaxRecipeImportBatch). Invalid
+// items, and names the user already has, are reported and skipped; the rest are
+// created in one transaction.
+func (h *RecipeHandler) ImportRecipes(w http.ResponseWriter, r *http.Request) {
+	var reqs []*models.CreateRecipeRequest
+	if err := decodeStrict(r, &reqs); err != nil {
+		writeBodyError(w, err, "Invalid request body: expected an array of recipes")
+		return
+	}
+	if len(reqs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "at least one recipe is required")
+		return
+	}
+	if len(reqs) > models.MaxRecipeImportBatch {
+		writeJSONError(w, http.StatusBadRequest, "at most "+strconv.Itoa(models.MaxRecipeImportBatch)+" recipes can be imported at once")
+		return
+	}
+
+	results := make([]models.RecipeImportResult, len(reqs))
+	var valid []*models.CreateRecipeRequest
+	var validIdx []int
+	for i, req := range reqs {
+		results[i].Index = i
+		if req == nil {
+			results[i].Error = "recipe is null"
+			continue
+		}
+		if msg := validateCreateRecipe(req); msg != "" {
+			results[i].Error = msg
+			continue
+		}
+		valid = append(valid, req)
+		validIdx = append(validIdx, i)
+	}
+
+	created := 0
+	if len(valid) > 0 {
+		userID := middleware.MustGetUserID(r)
+		ids, itemErrs, err := h.repo.CreateBatch(valid, userID, r.URL.Query().Get("allow_duplicate") == "true")
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to import recipes")
+			return
+		}
+		for j, i := range validIdx {
+			if itemErrs[j] != nil {
+				results[i].Error = itemErrs[j].Error()
+				continue
+			}
+			results[i].ID = ids[j]
+			created++
+			h.search.NotifyRecipeChange(ids[j])
+			h.enhancedSearch.NotifyRecipeChange(ids[j])
+			h.logger.LogFromRequest(r, "recipe_imported", ids[j])
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"created": created,
+		"failed":  len(reqs) - created,
+		"results": results,
+	})
+}
+
 // UpdateRecipe - PUT /api/recipes/{id}
 func (h *RecipeHandler) UpdateRecipe(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
 
 	var req models.UpdateRecipeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeStrict(r, &req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+	if msg := validateRecipeFields(req.PrepTimeMin, req.CookTimeMin, req.Servings, req.Difficulty, req.Steps); msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
 		return
 	}
 
@@ -119,15 +562,30 @@ func (h *RecipeHandler) UpdateRecipe(w http.ResponseWriter, r *http.Request) {
 	updated, err := h.repo.Update(id, &req, userID)
 	if err != nil {
 		if errors.Is(err, repository.ErrRecipeForbidden) {
-			http.Error(w, "Recipe can only be changed by its creator", http.StatusForbidden)
+			writeJSONError(w, http.StatusForbidden, "Recipe can only be changed by its creator")
+			return
+		}
+		if errors.Is(err, repository.ErrRecipeNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Recipe not found")
+			return
+		}
+		var invalid *repository.InvalidIngredientsError
+		if errors.As(err, &invalid) {
+			writeJSONError(w, http.StatusBadRequest, invalid.Error())
+			return
+		}
+		var repeated *repository.DuplicateIngredientsError
+		if errors.As(err, &repeated) {
+			writeJSONError(w, http.StatusBadRequest, repeated.Error())
 			return
 		}
-		http.Error(w, "Recipe not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to update recipe")
 		return
 	}
 
 	h.search.NotifyRecipeChange(id)
-	h.logger.Log("recipe_updated", id)
+	h.enhancedSearch.NotifyRecipeChange(id)
+	h.logger.LogFromRequest(r, "recipe_updated", id)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updated)
@@ -138,24 +596,164 @@ func (h *RecipeHandler) DeleteRecipe(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
 
 	userID := middleware.MustGetUserID(r)
 	if err := h.repo.Delete(id, userID); err != nil {
 		if errors.Is(err, repository.ErrRecipeForbidden) {
-			http.Error(w, "Recipe can only be deleted by its creator", http.StatusForbidden)
+			writeJSONError(w, http.StatusForbidden, "Recipe can only be deleted by its creator")
 			return
 		}
-		http.Error(w, "Recipe not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "Recipe not found")
 		return
 	}
 
-	h.logger.Log("recipe_deleted", id)
+	h.enhancedSearch.NotifyRecipeChange(id)
+	h.logger.LogFromRequest(r, "recipe_deleted", id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// RestoreRecipe - POST /api/recipes/{id}/restore
+func (h *RecipeHandler) RestoreRecipe(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	userID := middleware.MustGetUserID(r)
+	restored, err := h.repo.RestoreRecipe(id, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecipeForbidden) {
+			writeJSONError(w, http.StatusForbidden, "Recipe can only be restored by its creator")
+			return
+		}
+		if errors.Is(err, repository.ErrRecipeNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Deleted recipe not found")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to restore recipe")
+		return
+	}
+
+	h.search.NotifyRecipeChange(id)
+	h.enhancedSearch.NotifyRecipeChange(id)
+	h.logger.LogFromRequest(r, "recipe_restored", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restored)
+}
+
+// GetRecipeHistory - GET /api/recipes/{id}/history (protected, creator only)
+// Lists the recipe's previous versions, newest first.
+func (h *RecipeHandler) GetRecipeHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	userID := middleware.MustGetUserID(r)
+	versions, err := h.repo.GetHistory(id, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecipeForbidden) {
+			writeJSONError(w, http.StatusForbidden, "Recipe history is only available to its creator")
+			return
+		}
+		if errors.Is(err, repository.ErrRecipeNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Recipe not found")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load recipe history")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// RevertRecipe - POST /api/recipes/{id}/revert/{version} (protected, creator only)
+// Restores a previous version as the current content; the replaced content is kept
+// as a new version.
+func (h *RecipeHandler) RevertRecipe(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+	version, err := strconv.Atoi(vars["version"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid version")
+		return
+	}
+
+	userID := middleware.MustGetUserID(r)
+	reverted, err := h.repo.Revert(id, version, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecipeForbidden) {
+			writeJSONError(w, http.StatusForbidden, "Recipe can only be changed by its creator")
+			return
+		}
+		if errors.Is(err, repository.ErrRecipeNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Recipe not found")
+			return
+		}
+		if errors.Is(err, repository.ErrVersionNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Version not found")
+			return
+		}
+		var invalid *repository.InvalidIngredientsError
+		if errors.As(err, &invalid) {
+			writeJSONError(w, http.StatusConflict, "Version references ingredients that no longer exist: "+invalid.Error())
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to revert recipe")
+		return
+	}
+
+	h.search.NotifyRecipeChange(id)
+	h.enhancedSearch.NotifyRecipeChange(id)
+	h.logger.LogFromRequest(r, "recipe_reverted", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reverted)
+}
+
+// CloneRecipe - POST /api/recipes/{id}/clone (protected)
+// Copies any recipe into a new one owned by the authenticated user.
+func (h *RecipeHandler) CloneRecipe(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	userID := middleware.MustGetUserID(r)
+	clone, err := h.repo.Clone(id, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecipeNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Recipe not found")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to clone recipe")
+		return
+	}
+
+	h.search.NotifyRecipeChange(clone.ID)
+	h.enhancedSearch.NotifyRecipeChange(clone.ID)
+	h.logger.LogFromRequest(r, "recipe_cloned", clone.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(clone)
+}
+
 // ListIngredients - GET /api/ingredients
 func (h *RecipeHandler) ListIngredients(w http.ResponseWriter, r *http.Request) {
 	list := h.repo.ListIngredients()
@@ -167,33 +765,76 @@ func (h *RecipeHandler) ListIngredients(w http.ResponseWriter, r *http.Request)
 func (h *RecipeHandler) AdvancedIngredientSearch(w http.ResponseWriter, r *http.Request) {
 	var req recipe.SearchRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyError(w, err, "Invalid request body")
 		return
 	}
 
-	// Set default values
-	if req.MaxResults <= 0 {
-		req.MaxResults = 20
-	}
+	req.MaxResults = clampLimit(req.MaxResults, defaultPageLimit)
 
-	response := h.enhancedSearch.ComprehensiveSearch(req)
-	h.logger.Log("advanced_search", 0)
+	response, err := h.enhancedSearch.ComprehensiveSearch(req)
+	if err != nil {
+		log.Printf("advanced search (request %s): %v", middleware.GetRequestID(r), err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to search recipes")
+		return
+	}
+	h.logger.LogFromRequest(r, "advanced_search", 0)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// CheckPantry - POST /api/recipes/{id}/check-pantry with {"ingredients": [...]}.
+// Reports, for each of the recipe's ingredients, whether the pantry covers it and
+// how, and whether the recipe is makeable with no missing ingredients.
+func (h *RecipeHandler) CheckPantry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	var req models.CheckPantryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+	if len(req.Ingredients) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "ingredients is required")
+		return
+	}
+	if len(req.Ingredients) > models.MaxPantryIngredients {
+		writeJSONError(w, http.StatusBadRequest, "at most "+strconv.Itoa(models.MaxPantryIngredients)+" ingredients can be checked at once")
+		return
+	}
+
+	rec, err := h.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecipeNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Recipe not found")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load recipe")
+		return
+	}
+
+	check := h.enhancedSearch.CheckPantry(rec, req.Ingredients)
+	h.logger.LogFromRequest(r, "pantry_checked", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(check)
+}
+
 // GetIngredientSubstitutes - GET /api/ingredients/{name}/substitutes
 func (h *RecipeHandler) GetIngredientSubstitutes(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	ingredientName := vars["name"]
 	if ingredientName == "" {
-		http.Error(w, "Ingredient name is required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Ingredient name is required")
 		return
 	}
 
 	substitutes := h.enhancedSearch.GetIngredientSubstitutes(ingredientName)
-	h.logger.Log("ingredient_substitutes_viewed", 0)
+	h.logger.LogFromRequest(r, "ingredient_substitutes_viewed", 0)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string][]string{"substitutes": substitutes})
@@ -204,12 +845,12 @@ func (h *RecipeHandler) GetIngredientSynonyms(w http.ResponseWriter, r *http.Req
 	vars := mux.Vars(r)
 	ingredientName := vars["name"]
 	if ingredientName == "" {
-		http.Error(w, "Ingredient name is required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Ingredient name is required")
 		return
 	}
 
 	synonyms := h.enhancedSearch.GetIngredientSynonyms(ingredientName)
-	h.logger.Log("ingredient_synonyms_viewed", 0)
+	h.logger.LogFromRequest(r, "ingredient_synonyms_viewed", 0)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string][]string{"synonyms": synonyms})
@@ -223,17 +864,17 @@ func (h *RecipeHandler) AddIngredientSynonym(w http.ResponseWriter, r *http.Requ
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyError(w, err, "Invalid request body")
 		return
 	}
 
 	if req.Canonical == "" || req.Synonym == "" {
-		http.Error(w, "Both canonical and synonym are required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Both canonical and synonym are required")
 		return
 	}
 
 	h.enhancedSearch.AddIngredientSynonym(req.Canonical, req.Synonym)
-	h.logger.Log("ingredient_synonym_added", 0)
+	h.logger.LogFromRequest(r, "ingredient_synonym_added", 0)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -248,17 +889,17 @@ func (h *RecipeHandler) AddIngredientSubstitute(w http.ResponseWriter, r *http.R
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyError(w, err, "Invalid request body")
 		return
 	}
 
 	if req.Ingredient == "" || req.Substitute == "" {
-		http.Error(w, "Both ingredient and substitute are required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Both ingredient and substitute are required")
 		return
 	}
 
 	h.enhancedSearch.AddIngredientSubstitute(req.Ingredient, req.Substitute)
-	h.logger.Log("ingredient_substitute_added", 0)
+	h.logger.LogFromRequest(r, "ingredient_substitute_added", 0)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
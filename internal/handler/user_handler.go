@@ -2,24 +2,50 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"cooking-app/internal/logger"
+	"cooking-app/internal/middleware"
 	"cooking-app/internal/models"
 	"cooking-app/internal/repository"
 
 	"github.com/gorilla/mux"
 )
 
+// UserStore is the account storage UserHandler and AuthHandler need;
+// *repository.UserRepository implements it.
+type UserStore interface {
+	GetByID(id int) (*models.User, error)
+	GetByUsername(username string) (*models.User, error)
+	GetByEmail(email string) (*models.User, error)
+	GetAll() []*models.User
+	Create(user *models.User) *models.User
+	CreateWithPassword(username, email, hashedPassword, firstName, lastName string) (*models.User, error)
+	Update(id int, req *models.UpdateUserRequest) (*models.User, error)
+	UpdatePassword(id int, hashedPassword string) error
+	IsAdmin(id int) (bool, error)
+	Delete(id int) error
+	DeleteAccount(id int) (*models.AccountDeletionSummary, error)
+	CreatePasswordResetToken(userID int, tokenHash string, expiresAt time.Time) error
+	ResetPassword(tokenHash, hashedPassword string) error
+	CreateEmailVerificationToken(userID int, tokenHash string, expiresAt time.Time) error
+	VerifyEmail(tokenHash string) (*models.User, error)
+	CreateRefreshToken(userID int, tokenHash string, expiresAt time.Time) error
+	GetUserByRefreshToken(tokenHash string) (*models.User, error)
+	RevokeRefreshToken(tokenHash string) error
+}
+
 // UserHandler обрабатывает HTTP запросы для User Profile API
 type UserHandler struct {
-	repo   *repository.UserRepository
+	repo   UserStore
 	logger *logger.ActivityLogger
 }
 
 // NewUserHandler создает новый handler
-func NewUserHandler(repo *repository.UserRepository, log *logger.ActivityLogger) *UserHandler {
+func NewUserHandler(repo UserStore, log *logger.ActivityLogger) *UserHandler {
 	return &UserHandler{
 		repo:   repo,
 		logger: log,
@@ -31,17 +57,40 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
 	user, err := h.repo.GetByID(id)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "User not found")
 		return
 	}
 
-	h.logger.Log("profile_viewed", id)
+	h.logger.LogFromRequest(r, "profile_viewed", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// GetMyProfile - GET /api/profile/me (protected)
+// Returns the authenticated user's own profile, so clients needn't know their ID.
+func (h *UserHandler) GetMyProfile(w http.ResponseWriter, r *http.Request) {
+	id, ok := middleware.GetUserID(r)
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized - invalid or missing token")
+		return
+	}
+
+	user, err := h.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			writeJSONError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load profile")
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
@@ -59,13 +108,13 @@ func (h *UserHandler) GetAllProfiles(w http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) CreateProfile(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyError(w, err, "Invalid request body")
 		return
 	}
 
 	created := h.repo.Create(&user)
 
-	h.logger.Log("profile_created", created.ID)
+	h.logger.LogFromRequest(r, "profile_created", created.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -77,23 +126,27 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	if !h.authorizeProfileChange(w, r, id) {
 		return
 	}
 
 	var req models.UpdateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeStrict(r, &req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
 		return
 	}
 
 	updated, err := h.repo.Update(id, &req)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "User not found")
 		return
 	}
 
-	h.logger.Log("profile_updated", id)
+	h.logger.LogFromRequest(r, "profile_updated", id)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updated)
@@ -104,16 +157,39 @@ func (h *UserHandler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	if !h.authorizeProfileChange(w, r, id) {
 		return
 	}
 
 	if err := h.repo.Delete(id); err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "User not found")
 		return
 	}
 
-	h.logger.Log("profile_deleted", id)
+	h.logger.LogFromRequest(r, "profile_deleted", id)
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// authorizeProfileChange lets the authenticated user change only their own profile,
+// unless they are an admin. On refusal it writes the error response and returns false.
+func (h *UserHandler) authorizeProfileChange(w http.ResponseWriter, r *http.Request, profileID int) bool {
+	userID := middleware.MustGetUserID(r)
+	if userID == profileID {
+		return true
+	}
+	admin, err := h.repo.IsAdmin(userID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to check permissions")
+		return false
+	}
+	if !admin {
+		writeJSONError(w, http.StatusForbidden, "You can only change your own profile")
+		return false
+	}
+	return true
+}
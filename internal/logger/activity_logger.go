@@ -2,26 +2,39 @@ package logger
 
 import (
 	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"cooking-app/internal/middleware"
 )
 
 // Event представляет событие для логирования
 type Event struct {
-	Action    string
-	UserID    int
-	Timestamp time.Time
+	Action     string
+	UserID     int
+	ResourceID int    // recipe/comment/profile the action applies to, 0 if none
+	RequestID  string // set by LogFromRequest when RequestIDMiddleware is in use
+	Timestamp  time.Time
 }
 
 // ActivityLogger логирует действия пользователей асинхронно
 // Использует goroutine и channels (требование Assignment 4)
 type ActivityLogger struct {
-	events chan Event
+	events  chan Event
+	done    chan struct{} // closed once processEvents has drained events
+	dropped atomic.Uint64 // events discarded because the channel was full or closed
+
+	mu     sync.RWMutex // held for reading while sending on events, for writing by Close
+	closed bool
 }
 
 // NewActivityLogger создает новый логгер
 func NewActivityLogger() *ActivityLogger {
 	logger := &ActivityLogger{
 		events: make(chan Event, 100), // buffered channel
+		done:   make(chan struct{}),
 	}
 
 	// Запускаем goroutine для обработки событий (Assignment 4 requirement)
@@ -32,39 +45,87 @@ func NewActivityLogger() *ActivityLogger {
 
 // Log отправляет событие в channel (не блокирует)
 func (l *ActivityLogger) Log(action string, userID int) {
-	event := Event{
+	l.send(Event{
 		Action:    action,
 		UserID:    userID,
 		Timestamp: time.Now(),
-	}
+	})
+}
+
+// LogFromRequest logs an action attributed to the authenticated user of r
+// (user ID 0 for anonymous requests). resourceID is the affected object, or 0.
+func (l *ActivityLogger) LogFromRequest(r *http.Request, action string, resourceID int) {
+	userID, _ := middleware.GetUserID(r)
+	l.send(Event{
+		Action:     action,
+		UserID:     userID,
+		ResourceID: resourceID,
+		RequestID:  middleware.GetRequestID(r),
+		Timestamp:  time.Now(),
+	})
+}
 
+func (l *ActivityLogger) send(event Event) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		l.dropped.Add(1)
+		return
+	}
 	// Отправляем в channel (асинхронно)
 	select {
 	case l.events <- event:
 		// Событие отправлено
 	default:
 		// Channel переполнен, пропускаем
+		l.dropped.Add(1)
 		fmt.Println("Warning: Event log full, dropping event")
 	}
 }
 
+// QueueDepth returns the number of events waiting to be written.
+func (l *ActivityLogger) QueueDepth() int {
+	return len(l.events)
+}
+
+// Dropped returns how many events have been discarded because the queue was full.
+func (l *ActivityLogger) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
 // processEvents обрабатывает события в отдельной goroutine
 func (l *ActivityLogger) processEvents() {
 	fmt.Println("🚀 Activity logger goroutine started (Assignment 4 concurrency)")
+	defer close(l.done)
 
 	for event := range l.events {
 		// Симулируем асинхронную обработку
-		fmt.Printf("[LOG] %s | User ID: %d | Action: %s\n",
+		line := fmt.Sprintf("[LOG] %s | User ID: %d | Action: %s",
 			event.Timestamp.Format("15:04:05"),
 			event.UserID,
 			event.Action,
 		)
+		if event.ResourceID != 0 {
+			line += fmt.Sprintf(" | Resource ID: %d", event.ResourceID)
+		}
+		if event.RequestID != "" {
+			line += " | Request ID: " + event.RequestID
+		}
+		fmt.Println(line)
 
 		// Небольшая задержка для демонстрации async обработки
 		time.Sleep(10 * time.Millisecond)
 	}
 }
 
+// Close stops accepting events and waits until the buffered ones have been written.
+// Events logged after Close are dropped.
 func (l *ActivityLogger) Close() {
-	close(l.events)
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.events)
+	}
+	l.mu.Unlock()
+	<-l.done
 }
@@ -0,0 +1,60 @@
+// Package mailer delivers account emails such as password reset tokens.
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Message is one outgoing email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends messages. Tokens are only ever handed to a Mailer, never logged.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// NopMailer discards every message. It is the default until a transport is configured,
+// so reset and verification emails are not delivered.
+type NopMailer struct{}
+
+// Send drops msg, logging only that a message was discarded.
+func (NopMailer) Send(msg Message) error {
+	log.Printf("mailer: no mail transport configured; discarded %q", msg.Subject)
+	return nil
+}
+
+// DirMailer writes each message to its own file in a directory, for development
+// setups without a mail server. Files are readable only by the server's user.
+type DirMailer struct {
+	dir string
+	seq atomic.Uint64
+}
+
+// NewDirMailer creates dir if needed and returns a mailer writing into it.
+func NewDirMailer(dir string) (*DirMailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create mail outbox: %w", err)
+	}
+	return &DirMailer{dir: dir}, nil
+}
+
+// Send writes msg to <dir>/<timestamp>-<n>.eml.
+func (m *DirMailer) Send(msg Message) error {
+	name := fmt.Sprintf("%s-%d.eml", time.Now().UTC().Format("20060102T150405"), m.seq.Add(1))
+	var b strings.Builder
+	fmt.Fprintf(&b, "To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	if err := os.WriteFile(filepath.Join(m.dir, name), []byte(b.String()), 0o600); err != nil {
+		return fmt.Errorf("write mail %s: %w", name, err)
+	}
+	return nil
+}
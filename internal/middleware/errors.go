@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// writeJSONError writes the same {"error", "message"} body as the handler package's
+// writeJSONError, which middleware can't import.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": http.StatusText(status), "message": message})
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// AdminMiddleware restricts a route to admin users. It must run after
+// AuthMiddleware.Authenticate so the user ID is in the request context.
+type AdminMiddleware struct {
+	isAdmin func(userID int) (bool, error)
+}
+
+// NewAdminMiddleware creates the middleware.
+func NewAdminMiddleware(isAdmin func(userID int) (bool, error)) *AdminMiddleware {
+	return &AdminMiddleware{isAdmin: isAdmin}
+}
+
+// Handler returns 403 unless the authenticated user is an admin.
+func (m *AdminMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := GetUserID(r)
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "Unauthorized - invalid or missing token")
+			return
+		}
+
+		admin, err := m.isAdmin(userID)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to check admin status")
+			return
+		}
+		if !admin {
+			writeJSONError(w, http.StatusForbidden, "Admin access required")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
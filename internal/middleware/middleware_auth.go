@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 
@@ -12,6 +14,12 @@ type contextKey string
 
 const UserIDKey contextKey = "user_id"
 
+var (
+	errMissingToken   = errors.New("missing Authorization header")
+	errBadAuthHeader  = errors.New("authorization header is not a Bearer token")
+	errInvalidSubject = errors.New("token has no valid user_id")
+)
+
 // AuthMiddleware ...
 type AuthMiddleware struct {
 	authService *auth.Service
@@ -21,57 +29,56 @@ func NewAuthMiddleware(authService *auth.Service) *AuthMiddleware {
 	return &AuthMiddleware{authService: authService}
 }
 
-// extractAndValidateToken is shared logic for both required + optional auth
-func (m *AuthMiddleware) extractAndValidateToken(r *http.Request) (int, bool) {
+// extractAndValidateToken is shared logic for both required + optional auth.
+// The returned error says why the token was rejected.
+func (m *AuthMiddleware) extractAndValidateToken(r *http.Request) (int, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
-		return 0, false
+		return 0, errMissingToken
 	}
 
 	parts := strings.SplitN(authHeader, " ", 2)
 	if len(parts) != 2 || parts[0] != "Bearer" {
-		return 0, false
+		return 0, errBadAuthHeader
 	}
 
 	tokenStr := parts[1]
 	claims, err := m.authService.ValidateToken(tokenStr)
 	if err != nil {
-		return 0, false
+		return 0, err
 	}
 
-	userIDAny, ok := claims["user_id"]
-	if !ok {
-		return 0, false
+	if claims.UserID < 1 {
+		return 0, errInvalidSubject
 	}
+	return claims.UserID, nil
+}
 
-	// Safest way: try different possible numeric types
-	switch v := userIDAny.(type) {
-	case float64:
-		if v < 1 || v > 1<<31-1 { // reasonable guard for int32 user IDs
-			return 0, false
-		}
-		return int(v), true
-	case int:
-		if v < 1 {
-			return 0, false
-		}
-		return v, true
-	case int64:
-		if v < 1 || v > 1<<31-1 {
-			return 0, false
-		}
-		return int(v), true
+// unauthorizedMessage maps a token rejection reason to a client-facing message.
+func unauthorizedMessage(err error) string {
+	switch {
+	case errors.Is(err, errMissingToken):
+		return "Unauthorized - missing token"
+	case errors.Is(err, auth.ErrTokenExpired):
+		return "Unauthorized - token expired"
+	case errors.Is(err, auth.ErrTokenMalformed), errors.Is(err, errBadAuthHeader):
+		return "Unauthorized - malformed token"
+	case errors.Is(err, auth.ErrTokenRevoked):
+		return "Unauthorized - token revoked"
 	default:
-		return 0, false
+		return "Unauthorized - invalid token"
 	}
 }
 
 // Authenticate — requires valid token
 func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID, ok := m.extractAndValidateToken(r)
-		if !ok {
-			http.Error(w, "Unauthorized - invalid or missing token", http.StatusUnauthorized)
+		userID, err := m.extractAndValidateToken(r)
+		if err != nil {
+			if !errors.Is(err, errMissingToken) {
+				log.Printf("auth: rejected token for %s %s (request %s): %v", r.Method, r.URL.Path, GetRequestID(r), err)
+			}
+			writeJSONError(w, http.StatusUnauthorized, unauthorizedMessage(err))
 			return
 		}
 
@@ -83,8 +90,8 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 // OptionalAuth — attaches user_id only if token is valid, otherwise continues
 func (m *AuthMiddleware) OptionalAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID, ok := m.extractAndValidateToken(r)
-		if ok {
+		userID, err := m.extractAndValidateToken(r)
+		if err == nil {
 			ctx := context.WithValue(r.Context(), UserIDKey, userID)
 			r = r.WithContext(ctx)
 		}
@@ -0,0 +1,36 @@
+package middleware
+
+import "net/http"
+
+// defaultMaxBodyBytes is used when NewBodyLimitMiddleware is given a limit <= 0.
+const defaultMaxBodyBytes = 1 << 20 // 1 MiB
+
+// BodyLimitMiddleware bounds request bodies so a huge upload can't exhaust memory.
+type BodyLimitMiddleware struct {
+	maxBytes int64
+}
+
+// NewBodyLimitMiddleware creates the middleware. maxBytes <= 0 uses a 1 MiB default.
+func NewBodyLimitMiddleware(maxBytes int64) *BodyLimitMiddleware {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBodyBytes
+	}
+	return &BodyLimitMiddleware{maxBytes: maxBytes}
+}
+
+// Handler rejects requests that declare a larger Content-Length with 413 and wraps
+// the body in http.MaxBytesReader, so reading past the limit fails with
+// *http.MaxBytesError for handlers to turn into a 413 as well.
+func (m *BodyLimitMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > m.maxBytes {
+			w.Header().Set("Connection", "close")
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, m.maxBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -29,13 +29,18 @@ func (m *CORSMiddleware) Handler(next http.Handler) http.Handler {
 			if len(m.allowedOrigins) == 1 && m.allowedOrigins[0] == "*" {
 				w.Header().Set("Access-Control-Allow-Origin", "*")
 			} else if origin != "" {
+				// Echo the matched origin: browsers reject credentials with "*"
 				w.Header().Set("Access-Control-Allow-Origin", origin)
 				allowCredentials = true
 			}
 		}
+		if !(len(m.allowedOrigins) == 1 && m.allowedOrigins[0] == "*") {
+			w.Header().Add("Vary", "Origin")
+		}
 
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+RequestIDHeader)
+		w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader+", X-Total-Count, Link")
 		// Only set Credentials header when not using wildcard origin (CORS spec requirement)
 		if allowCredentials {
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
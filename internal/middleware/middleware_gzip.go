@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// defaultGzipMinSize is the smallest response worth compressing; below this the
+// gzip header and CPU cost outweigh the savings.
+const defaultGzipMinSize = 1024
+
+// skipGzipPrefixes lists content types that are already compressed.
+var skipGzipPrefixes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/pdf",
+}
+
+// GzipMiddleware compresses responses for clients that send Accept-Encoding: gzip.
+type GzipMiddleware struct {
+	minSize int
+	writers sync.Pool
+}
+
+// NewGzipMiddleware creates the middleware. Responses smaller than minSize bytes are
+// sent uncompressed; minSize <= 0 uses a 1 KiB default.
+func NewGzipMiddleware(minSize int) *GzipMiddleware {
+	if minSize <= 0 {
+		minSize = defaultGzipMinSize
+	}
+	return &GzipMiddleware{
+		minSize: minSize,
+		writers: sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }},
+	}
+}
+
+// Handler wraps the response writer with a gzip writer when the client supports it.
+func (m *GzipMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, m: m}
+		defer gw.finish()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of the body until it knows whether the
+// response is big enough, and of a suitable type, to compress.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	m       *GzipMiddleware
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.m.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// decide sends the headers and buffered bytes, compressing when allowed and compress is true.
+func (w *gzipResponseWriter) decide(compress bool) error {
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if compress && w.status != http.StatusPartialContent && h.Get("Content-Encoding") == "" &&
+		compressibleType(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz = w.m.writers.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// Flush sends everything written so far. A response flushed before the size
+// decision is streamed uncompressed, so server-sent events aren't held back.
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish flushes a small (never-compressed) body or closes the gzip stream.
+func (w *gzipResponseWriter) finish() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			return
+		}
+		w.decide(false)
+		return
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		w.m.writers.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+func compressibleType(contentType string) bool {
+	for _, prefix := range skipGzipPrefixes {
+		if strings.HasPrefix(contentType, prefix) {
+			return !strings.HasPrefix(contentType, "image/svg")
+		}
+	}
+	return true
+}
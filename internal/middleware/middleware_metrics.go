@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request latency histogram.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// unmatchedRoute labels requests that matched no route, so arbitrary paths can't
+// create unbounded label values.
+const unmatchedRoute = "unmatched"
+
+type requestKey struct {
+	method, route string
+	status        int
+}
+
+type routeKey struct {
+	method, route string
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative; the last entry is +Inf
+	sum    float64
+	count  uint64
+}
+
+type funcMetric struct {
+	name, help, kind string
+	value            func() float64
+}
+
+// MetricsMiddleware counts requests and records their latency per route, and serves
+// the results in the Prometheus text exposition format.
+type MetricsMiddleware struct {
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	latency  map[routeKey]*histogram
+	funcs    []funcMetric
+}
+
+// NewMetricsMiddleware creates the middleware.
+func NewMetricsMiddleware() *MetricsMiddleware {
+	return &MetricsMiddleware{
+		requests: make(map[requestKey]uint64),
+		latency:  make(map[routeKey]*histogram),
+	}
+}
+
+// AddGaugeFunc exposes the value returned by fn, read at scrape time, as a gauge.
+func (m *MetricsMiddleware) AddGaugeFunc(name, help string, fn func() float64) {
+	m.addFunc(name, help, "gauge", fn)
+}
+
+// AddCounterFunc exposes the value returned by fn, which must never decrease, as a counter.
+func (m *MetricsMiddleware) AddCounterFunc(name, help string, fn func() float64) {
+	m.addFunc(name, help, "counter", fn)
+}
+
+func (m *MetricsMiddleware) addFunc(name, help, kind string, fn func() float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.funcs = append(m.funcs, funcMetric{name: name, help: help, kind: kind, value: fn})
+}
+
+// Handler records the method, route template, status and duration of each request.
+// Routes are labelled by their mux path template (e.g. /api/recipes/{id}), not the raw path.
+func (m *MetricsMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		m.observe(r.Method, routeLabel(r), rec.statusCode(), time.Since(start))
+	})
+}
+
+func routeLabel(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return unmatchedRoute
+}
+
+func (m *MetricsMiddleware) observe(method, route string, status int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{method, route, status}]++
+
+	h := m.latency[routeKey{method, route}]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets)+1)}
+		m.latency[routeKey{method, route}] = h
+	}
+	secs := d.Seconds()
+	i := sort.SearchFloat64s(latencyBuckets, secs)
+	h.counts[i]++
+	h.sum += secs
+	h.count++
+}
+
+// Expose serves all metrics in the Prometheus text format (GET /metrics).
+func (m *MetricsMiddleware) Expose(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	m.mu.Lock()
+
+	b.WriteString("# HELP http_requests_total Total HTTP requests by method, route and status code.\n")
+	b.WriteString("# TYPE http_requests_total counter\n")
+	reqKeys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		reqKeys = append(reqKeys, k)
+	}
+	sort.Slice(reqKeys, func(i, j int) bool {
+		a, c := reqKeys[i], reqKeys[j]
+		if a.route != c.route {
+			return a.route < c.route
+		}
+		if a.method != c.method {
+			return a.method < c.method
+		}
+		return a.status < c.status
+	})
+	for _, k := range reqKeys {
+		fmt.Fprintf(&b, "http_requests_total{method=\"%s\",route=\"%s\",status=\"%d\"} %d\n",
+			escapeLabel(k.method), escapeLabel(k.route), k.status, m.requests[k])
+	}
+
+	b.WriteString("# HELP http_request_duration_seconds Request latency by method and route.\n")
+	b.WriteString("# TYPE http_request_duration_seconds histogram\n")
+	latKeys := make([]routeKey, 0, len(m.latency))
+	for k := range m.latency {
+		latKeys = append(latKeys, k)
+	}
+	sort.Slice(latKeys, func(i, j int) bool {
+		if latKeys[i].route != latKeys[j].route {
+			return latKeys[i].route < latKeys[j].route
+		}
+		return latKeys[i].method < latKeys[j].method
+	})
+	for _, k := range latKeys {
+		h := m.latency[k]
+		labels := fmt.Sprintf("method=\"%s\",route=\"%s\"", escapeLabel(k.method), escapeLabel(k.route))
+		var cumulative uint64
+		for i, bound := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, strconv.FormatFloat(bound, 'f', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(&b, "http_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(h.sum, 'f', -1, 64))
+		fmt.Fprintf(&b, "http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	funcs := append([]funcMetric(nil), m.funcs...)
+	m.mu.Unlock()
+
+	// Function metrics are read outside the lock; they may take locks of their own.
+	for _, f := range funcs {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %s\n",
+			f.name, f.help, f.name, f.kind, f.name, strconv.FormatFloat(f.value(), 'f', -1, 64))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// labelEscaper applies the exposition format's escaping for label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Flush passes flushes through so streaming handlers (server-sent events) still work.
+func (r *statusRecorder) Flush() {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// statusCode is the status sent, or 200 if the handler wrote nothing.
+func (r *statusRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// limiterIdleTimeout is how long a client can stay quiet before its bucket is evicted.
+const limiterIdleTimeout = time.Hour
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimiter applies a per-IP token bucket to incoming requests.
+type RateLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*clientLimiter
+	rate    rate.Limit
+	burst   int
+}
+
+// NewRateLimiter creates a limiter allowing requestsPerMinute per client IP with the
+// given burst, and starts a background goroutine that evicts idle clients.
+func NewRateLimiter(requestsPerMinute, burst int) *RateLimiter {
+	if requestsPerMinute <= 0 {
+		requestsPerMinute = 60
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	rl := &RateLimiter{
+		clients: make(map[string]*clientLimiter),
+		rate:    rate.Limit(float64(requestsPerMinute) / 60),
+		burst:   burst,
+	}
+	go rl.cleanupClients()
+	return rl
+}
+
+// Handler rejects requests over the limit with 429 and a Retry-After header.
+func (rl *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reservation := rl.getLimiter(clientIP(r)).Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			writeJSONError(w, http.StatusTooManyRequests, "Too many requests")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rl *RateLimiter) getLimiter(ip string) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	c, exists := rl.clients[ip]
+	if !exists {
+		c = &clientLimiter{limiter: rate.NewLimiter(rl.rate, rl.burst)}
+		rl.clients[ip] = c
+	}
+	c.lastSeen = time.Now()
+	return c.limiter
+}
+
+// cleanupClients periodically removes clients idle for longer than limiterIdleTimeout.
+func (rl *RateLimiter) cleanupClients() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rl.mu.Lock()
+		for ip, c := range rl.clients {
+			if time.Since(c.lastSeen) > limiterIdleTimeout {
+				delete(rl.clients, ip)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// clientIP returns the host part of r.RemoteAddr so every connection from one IP
+// shares a bucket regardless of source port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// EmailVerifiedMiddleware blocks users who haven't verified their email. It must run
+// after AuthMiddleware.Authenticate so the user ID is in the request context.
+type EmailVerifiedMiddleware struct {
+	required   bool
+	isVerified func(userID int) (bool, error)
+}
+
+// NewEmailVerifiedMiddleware creates the middleware. When required is false every
+// request passes through unchanged.
+func NewEmailVerifiedMiddleware(required bool, isVerified func(userID int) (bool, error)) *EmailVerifiedMiddleware {
+	return &EmailVerifiedMiddleware{
+		required:   required,
+		isVerified: isVerified,
+	}
+}
+
+// Handler returns 403 when verification is required and the user hasn't verified.
+func (m *EmailVerifiedMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.required {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, ok := GetUserID(r)
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "Unauthorized - invalid or missing token")
+			return
+		}
+
+		verified, err := m.isVerified(userID)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to check email verification")
+			return
+		}
+		if !verified {
+			writeJSONError(w, http.StatusForbidden, "Email verification required; request a new token with POST /api/auth/resend-verification")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// UserFavorite is a recipe bookmarked by a user.
+type UserFavorite struct {
+	UserID   int       `json:"user_id"`
+	RecipeID int       `json:"recipe_id"`
+	SavedAt  time.Time `json:"saved_at"`
+	Recipe   *Recipe   `json:"recipe,omitempty"`
+}
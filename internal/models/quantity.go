@@ -0,0 +1,91 @@
+package models
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseQuantity splits a free-text quantity such as "2 cups", "1 1/2 tbsp", "0.5kg"
+// or "3" into a numeric amount and a unit. ok is false for quantities with no
+// leading number ("pinch", "to taste"), which can't be scaled.
+func ParseQuantity(q string) (amount float64, unit string, ok bool) {
+	q = strings.TrimSpace(q)
+
+	// Split the leading number off a unit written without a space ("100g").
+	end := strings.IndexFunc(q, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.' && r != '/' && r != ' '
+	})
+	numPart, unit := q, ""
+	if end >= 0 {
+		numPart, unit = q[:end], q[end:]
+	}
+
+	unit = strings.TrimSpace(unit)
+	if unit != "" && !unicode.IsLetter([]rune(unit)[0]) {
+		return 0, "", false // ranges like "2-3 cloves"
+	}
+
+	fields := strings.Fields(numPart)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, "", false
+	}
+	for _, f := range fields {
+		n, valid := parseNumber(f)
+		if !valid {
+			return 0, "", false
+		}
+		amount += n
+	}
+	if amount <= 0 {
+		return 0, "", false
+	}
+	return amount, unit, true
+}
+
+// parseNumber parses "2", "0.5" or "1/2".
+func parseNumber(s string) (float64, bool) {
+	if num, den, found := strings.Cut(s, "/"); found {
+		n, err1 := strconv.ParseFloat(num, 64)
+		d, err2 := strconv.ParseFloat(den, 64)
+		if err1 != nil || err2 != nil || d == 0 {
+			return 0, false
+		}
+		return n / d, true
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// FormatQuantity renders an amount (rounded to two decimals) and unit back to text.
+func FormatQuantity(amount float64, unit string) string {
+	s := strconv.FormatFloat(math.Round(amount*100)/100, 'f', -1, 64)
+	if unit == "" {
+		return s
+	}
+	return s + " " + unit
+}
+
+// ScaleServings rescales the recipe's parsed ingredient amounts from its base
+// Servings (treated as 1 when unset) to servings. Ingredients without a numeric
+// amount are left unchanged.
+func (r *Recipe) ScaleServings(servings int) {
+	base := max(r.Servings, 1)
+	if servings < 1 || servings == base {
+		return
+	}
+	factor := float64(servings) / float64(base)
+	for i := range r.Ingredients {
+		ri := &r.Ingredients[i]
+		if ri.Amount == 0 {
+			continue
+		}
+		ri.Amount = math.Round(ri.Amount*factor*100) / 100
+		ri.Quantity = FormatQuantity(ri.Amount, ri.Unit)
+	}
+	r.Servings = servings
+}
@@ -7,33 +7,77 @@ type Rating struct {
 	RecipeID  int       `json:"recipe_id"`
 	UserID    int       `json:"user_id"`
 	Rating    int       `json:"rating"`
+	Cooked    bool      `json:"cooked"` // the rater says they made the recipe
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// UserRating is one of a user's ratings with the rated recipe's name.
+type UserRating struct {
+	Rating
+	RecipeName string `json:"recipe_name"`
+}
+
 type RatingStats struct {
 	RecipeID        int         `json:"recipe_id"`
 	AverageRating   float64     `json:"average_rating"`
 	TotalRatings    int         `json:"total_ratings"`
+	CookedCount     int         `json:"cooked_count"` // ratings from users who made the recipe
 	RatingBreakdown map[int]int `json:"rating_breakdown"`
+	// RatingPercentages is each star value's share of TotalRatings (0-100, one decimal).
+	// Unlike RatingBreakdown it always has all keys 1-5.
+	RatingPercentages map[int]float64 `json:"rating_percentages"`
 }
 
 type Comment struct {
 	ID        int       `json:"id"`
 	RecipeID  int       `json:"recipe_id"`
 	UserID    int       `json:"user_id"`
+	ParentID  *int      `json:"parent_id,omitempty"` // set for replies
 	Username  string    `json:"username,omitempty"`
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// CommentPage is one page of a recipe's comments plus the total across all pages.
+type CommentPage struct {
+	Comments   []*Comment `json:"comments"`
+	TotalCount int        `json:"total_count"`
+	Limit      int        `json:"limit"`
+	Offset     int        `json:"offset"`
+}
+
+// ReportCommentRequest flags a comment for moderation.
+type ReportCommentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CommentReport is one user's flag on a comment.
+type CommentReport struct {
+	ID             int       `json:"id"`
+	CommentID      int       `json:"comment_id"`
+	ReporterUserID int       `json:"reporter_user_id"`
+	Reason         string    `json:"reason"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ReportedComment is a flagged comment with its report count, for the admin queue.
+type ReportedComment struct {
+	Comment        *Comment  `json:"comment"`
+	ReportCount    int       `json:"report_count"`
+	Reasons        []string  `json:"reasons"`
+	LastReportedAt time.Time `json:"last_reported_at"`
+}
+
 type CreateRatingRequest struct {
-	Rating int `json:"rating"`
+	Rating int  `json:"rating"`
+	Cooked bool `json:"cooked"`
 }
 
 type CreateCommentRequest struct {
-	Content string `json:"content"`
+	Content  string `json:"content"`
+	ParentID *int   `json:"parent_id,omitempty"` // reply to this comment (same recipe)
 }
 
 type UpdateCommentRequest struct {
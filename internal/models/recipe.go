@@ -1,6 +1,47 @@
 package models
 
-import "time"
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recipe difficulty levels; the recipes.difficulty column only accepts these.
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// ValidDifficulty reports whether d is one of the difficulty levels.
+func ValidDifficulty(d string) bool {
+	return d == DifficultyEasy || d == DifficultyMedium || d == DifficultyHard
+}
+
+// MaxRecipeSteps caps the number of structured steps a recipe may have.
+const MaxRecipeSteps = 100
+
+// NormalizeSteps trims each step and drops blank ones, so the remaining steps are
+// numbered consecutively by their position. It never returns nil.
+func NormalizeSteps(steps []string) []string {
+	out := make([]string, 0, len(steps))
+	for _, s := range steps {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// StepsText renders steps as numbered lines ("1. ...") for the free-text instructions
+// field, so clients that only read instructions still see them.
+func StepsText(steps []string) string {
+	lines := make([]string, len(steps))
+	for i, s := range steps {
+		lines[i] = strconv.Itoa(i+1) + ". " + s
+	}
+	return strings.Join(lines, "\n")
+}
 
 type Recipe struct {
 	ID           int               `json:"id"`
@@ -11,14 +52,53 @@ type Recipe struct {
 	CookTimeMin  int               `json:"cook_time_min"`
 	Ingredients  []RecipeIngredient `json:"ingredients"`
 	UserID       *int               `json:"user_id,omitempty"` // creator; nil for legacy recipes
+	Servings     int               `json:"servings"`          // base serving count ingredient amounts are for
+	Difficulty   string            `json:"difficulty"`        // easy, medium or hard
 	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"` // last content change
+	ViewCount    int               `json:"view_count"`
+	Orphaned     bool              `json:"-"` // creator deleted their account; read-only
+	Steps        []string          `json:"steps"` // structured instructions, in order; may be empty
+	Calories     *int              `json:"calories,omitempty"`
+	ProteinG     *float64          `json:"protein_g,omitempty"`
+	CarbsG       *float64          `json:"carbs_g,omitempty"`
+	FatG         *float64          `json:"fat_g,omitempty"`
+}
+
+// Nutrition is the per-recipe nutrition block; unknown values are null.
+type Nutrition struct {
+	RecipeID int      `json:"recipe_id"`
+	Calories *int     `json:"calories"`
+	ProteinG *float64 `json:"protein_g"`
+	CarbsG   *float64 `json:"carbs_g"`
+	FatG     *float64 `json:"fat_g"`
+}
+
+// RecipeDetail is the GET /api/recipes/{id} response. MyRating and IsFavorite are
+// only populated when the request is authenticated.
+type RecipeDetail struct {
+	*Recipe
+	AverageRating float64 `json:"average_rating"`
+	TotalRatings  int     `json:"total_ratings"`
+	MyRating      *int    `json:"my_rating,omitempty"`
+	IsFavorite    *bool   `json:"is_favorite,omitempty"`
+}
+
+// PopularRecipe is a recipe with its aggregate rating, as returned by /api/recipes/popular.
+type PopularRecipe struct {
+	*Recipe
+	AverageRating float64 `json:"average_rating"`
+	TotalRatings  int     `json:"total_ratings"`
 }
 
 type RecipeIngredient struct {
 	RecipeID     int        `json:"recipe_id"`
 	IngredientID int        `json:"ingredient_id"`
+	Name         string     `json:"name,omitempty"` // create/update only: used when IngredientID is 0
 	Ingredient   Ingredient `json:"ingredient,omitempty"`
-	Quantity     string     `json:"quantity"` // e.g. "2 cups", "100g"
+	Quantity     string     `json:"quantity"`         // e.g. "2 cups", "100g"
+	Amount       float64    `json:"amount,omitempty"` // parsed from Quantity on read; 0 if not numeric
+	Unit         string     `json:"unit,omitempty"`   // parsed from Quantity on read
 }
 
 type Ingredient struct {
@@ -26,6 +106,71 @@ type Ingredient struct {
 	Name string `json:"name"`
 }
 
+// IngredientSuggestion is one autocomplete result. ID is 0 for synonyms that aren't
+// stored as ingredients.
+type IngredientSuggestion struct {
+	ID      int    `json:"id,omitempty"`
+	Name    string `json:"name"`
+	Synonym bool   `json:"synonym,omitempty"`
+}
+
+// MatchedField lists the search terms found in one recipe field ("name" or "description").
+type MatchedField struct {
+	Field string   `json:"field"`
+	Terms []string `json:"terms"`
+}
+
+// RecipeSearchResult is a recipe with the fields a text search matched, returned by
+// GET /api/recipes?explain=true.
+type RecipeSearchResult struct {
+	Recipe        *Recipe        `json:"recipe"`
+	MatchedFields []MatchedField `json:"matched_fields"`
+}
+
+// MaxNormalizeIngredients caps the names in one NormalizeIngredientsRequest.
+const MaxNormalizeIngredients = 100
+
+// MaxPantryIngredients caps the ingredients in one CheckPantryRequest.
+const MaxPantryIngredients = 200
+
+// CheckPantryRequest lists what the user has on hand.
+type CheckPantryRequest struct {
+	Ingredients []string `json:"ingredients"`
+}
+
+// NormalizeIngredientsRequest asks for the canonical form of ingredient names.
+type NormalizeIngredientsRequest struct {
+	Names []string `json:"names"`
+}
+
+// IngredientUsage is an ingredient with the number of recipes that use it.
+type IngredientUsage struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	RecipeCount int    `json:"recipe_count"`
+}
+
+// MergeIngredientsRequest for POST /api/admin/ingredients/merge: FromID is folded into ToID.
+type MergeIngredientsRequest struct {
+	FromID int `json:"from_id"`
+	ToID   int `json:"to_id"`
+}
+
+// IngredientMergeResult describes a completed merge. Recipes that already used the
+// target keep their own quantity, and their link to the merged ingredient is dropped.
+type IngredientMergeResult struct {
+	Merged    Ingredient `json:"merged"` // deleted
+	Into      Ingredient `json:"into"`
+	Relinked  int        `json:"relinked"`
+	Dropped   int        `json:"dropped"`
+	RecipeIDs []int      `json:"recipe_ids"` // every recipe that used the merged ingredient
+}
+
+// CreateIngredientRequest for POST /api/ingredients.
+type CreateIngredientRequest struct {
+	Name string `json:"name"`
+}
+
 type CreateRecipeRequest struct {
 	Name         string            `json:"name"`
 	Description  string            `json:"description"`
@@ -33,6 +178,13 @@ type CreateRecipeRequest struct {
 	PrepTimeMin  int               `json:"prep_time_min"`
 	CookTimeMin  int               `json:"cook_time_min"`
 	Ingredients  []RecipeIngredient `json:"ingredients"`
+	Steps        []string          `json:"steps,omitempty"` // when instructions is empty, it is filled from the steps
+	Servings     *int              `json:"servings,omitempty"` // default 1 on create, unchanged on update
+	Difficulty   string            `json:"difficulty,omitempty"` // default medium on create, unchanged on update
+	Calories     *int              `json:"calories,omitempty"`
+	ProteinG     *float64          `json:"protein_g,omitempty"`
+	CarbsG       *float64          `json:"carbs_g,omitempty"`
+	FatG         *float64          `json:"fat_g,omitempty"`
 }
 
 type UpdateRecipeRequest struct {
@@ -42,4 +194,48 @@ type UpdateRecipeRequest struct {
 	PrepTimeMin  int               `json:"prep_time_min"`
 	CookTimeMin  int               `json:"cook_time_min"`
 	Ingredients  []RecipeIngredient `json:"ingredients"`
+	Steps        []string          `json:"steps,omitempty"` // replaces all steps; omitted keeps them, [] clears them
+	Servings     *int              `json:"servings,omitempty"`
+	Difficulty   string            `json:"difficulty,omitempty"`
+	Calories     *int              `json:"calories,omitempty"`
+	ProteinG     *float64          `json:"protein_g,omitempty"`
+	CarbsG       *float64          `json:"carbs_g,omitempty"`
+	FatG         *float64          `json:"fat_g,omitempty"`
+}
+
+// MaxRecipeBatchIDs caps the IDs in one RecipeBatchRequest.
+const MaxRecipeBatchIDs = 100
+
+// RecipeBatchRequest is the body of POST /api/recipes/batch.
+type RecipeBatchRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// MaxRecipeImportBatch caps the number of recipes accepted by one bulk import.
+const MaxRecipeImportBatch = 100
+
+// RecipeImportResult is the outcome of one item of a bulk import: the created
+// recipe's ID, or the reason it was skipped.
+type RecipeImportResult struct {
+	Index int    `json:"index"`
+	ID    int    `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// RecipeVersion is a snapshot of a recipe's content taken just before an update
+// replaced it. Versions are numbered from 1 per recipe and never change.
+type RecipeVersion struct {
+	RecipeID     int                `json:"recipe_id"`
+	Version      int                `json:"version"`
+	Name         string             `json:"name"`
+	Description  string             `json:"description"`
+	Instructions string             `json:"instructions"`
+	PrepTimeMin  int                `json:"prep_time_min"`
+	CookTimeMin  int                `json:"cook_time_min"`
+	Servings     int                `json:"servings"`
+	Difficulty   string             `json:"difficulty"`
+	Ingredients  []RecipeIngredient `json:"ingredients"`
+	Steps        []string           `json:"steps"`
+	ReplacedBy   *int               `json:"replaced_by,omitempty"` // user whose edit replaced this version
+	ReplacedAt   time.Time          `json:"replaced_at"`
 }
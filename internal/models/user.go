@@ -4,14 +4,18 @@ import "time"
 
 // User represents a user profile with authentication.
 type User struct {
-	ID        int       `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"` // never send in JSON
-	FirstName string    `json:"first_name,omitempty"`
-	LastName  string    `json:"last_name,omitempty"`
-	Bio       string    `json:"bio,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Password  string `json:"-"` // never send in JSON
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+	Bio       string `json:"bio,omitempty"`
+	// EmailVerified is false until the user consumes their verification token.
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	// UpdatedAt is the time of the last profile edit (equal to CreatedAt until then).
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // UpdateUserRequest for updating user profile.
@@ -36,8 +40,52 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
-// AuthResponse returned after successful login/register.
-type AuthResponse struct {
+// ForgotPasswordRequest starts a password reset.
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest completes a password reset with the emailed token.
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// VerifyEmailRequest consumes an email verification token.
+type VerifyEmailRequest struct {
 	Token string `json:"token"`
-	User  *User  `json:"user"`
+}
+
+// ChangePasswordRequest for an authenticated password change.
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+// DeleteAccountRequest confirms account deletion with the user's current password.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// AccountDeletionSummary reports what was removed along with a deleted account.
+// Recipes are kept but lose their creator; CommentsDeleted includes other users'
+// replies to the account's comments.
+type AccountDeletionSummary struct {
+	RatingsDeleted   int `json:"ratings_deleted"`
+	CommentsDeleted  int `json:"comments_deleted"`
+	FavoritesDeleted int `json:"favorites_deleted"`
+	RecipesOrphaned  int `json:"recipes_orphaned"`
+}
+
+// RefreshRequest exchanges (or, on logout, revokes) a refresh token.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// AuthResponse returned after successful login/register/refresh.
+type AuthResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"` // access token lifetime in seconds
+	User         *User  `json:"user"`
 }
@@ -1,6 +1,8 @@
 package recipe
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -10,13 +12,15 @@ import (
 // RecipeRepository interface for recipe operations
 type RecipeRepository interface {
 	GetAll() []*models.Recipe
+	GetPaged(limit, offset int) []*models.Recipe
 	GetByID(id int) (*models.Recipe, error)
-	Create(req *models.CreateRecipeRequest, userID int) *models.Recipe
+	Create(req *models.CreateRecipeRequest, userID int, allowDuplicate bool) (*models.Recipe, error)
 	Update(id int, req *models.UpdateRecipeRequest, userID int) (*models.Recipe, error)
 	Delete(id int, userID int) error
 	SearchByName(query string) []*models.Recipe
-	SearchByIngredients(names []string) []*models.Recipe
+	SearchByIngredients(names []string, matchAny bool) []*models.Recipe
 	ListIngredients() []*models.Ingredient
+	RecipeIDsWithMinRating(minRating float64, minVotes int) (map[int]bool, error)
 }
 
 // EnhancedSearchService encapsulates advanced recipe search logic with ingredient matching
@@ -29,10 +33,11 @@ type EnhancedSearchService struct {
 }
 
 // NewEnhancedSearchService creates an enhanced search service with ingredient matching
-func NewEnhancedSearchService(repo RecipeRepository) *EnhancedSearchService {
+// scored by matchConfig (see DefaultMatchConfig).
+func NewEnhancedSearchService(repo RecipeRepository, matchConfig MatchConfig) *EnhancedSearchService {
 	s := &EnhancedSearchService{
 		repo:              repo,
-		ingredientMatcher: NewIngredientMatcher(repo),
+		ingredientMatcher: NewIngredientMatcher(repo, matchConfig),
 		index:            make(map[string][]int),
 		indexCh:          make(chan int, 50),
 	}
@@ -121,8 +126,10 @@ func (s *EnhancedSearchService) rebuildIndex() {
 	}
 }
 
-// NotifyRecipeChange notifies the indexer that a recipe was added or updated
+// NotifyRecipeChange notifies the indexer that a recipe was added, updated or deleted
+// and invalidates the matcher's recipe snapshot
 func (s *EnhancedSearchService) NotifyRecipeChange(recipeID int) {
+	s.ingredientMatcher.InvalidateCache()
 	select {
 	case s.indexCh <- recipeID:
 	default:
@@ -135,14 +142,56 @@ func (s *EnhancedSearchService) SearchByName(query string) []*models.Recipe {
 	return s.repo.SearchByName(query)
 }
 
+// SearchIndexed looks up each query term in the in-memory keyword index and returns
+// recipe IDs ranked by how many terms they match (ties broken by ID ascending).
+func (s *EnhancedSearchService) SearchIndexed(query string) []int {
+	words := strings.Fields(strings.ToLower(query))
+	terms := make(map[string]bool)
+	for _, w := range words {
+		w = strings.Trim(w, ".,!?")
+		if len(w) >= 2 {
+			terms[w] = true
+		}
+	}
+	if len(terms) == 0 {
+		return []int{}
+	}
+
+	s.mu.RLock()
+	hits := make(map[int]int)
+	for term := range terms {
+		for _, id := range s.index[term] {
+			hits[id]++
+		}
+	}
+	s.mu.RUnlock()
+
+	ids := make([]int, 0, len(hits))
+	for id := range hits {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if hits[ids[i]] != hits[ids[j]] {
+			return hits[ids[i]] > hits[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
 // SearchByIngredients returns recipes that contain all given ingredients (exact match)
 func (s *EnhancedSearchService) SearchByIngredients(names []string) []*models.Recipe {
-	return s.repo.SearchByIngredients(names)
+	return s.repo.SearchByIngredients(names, false)
 }
 
 // AdvancedIngredientSearch performs sophisticated ingredient matching with scoring
-func (s *EnhancedSearchService) AdvancedIngredientSearch(userIngredients []string, maxResults int) []RecipeMatchResult {
-	return s.ingredientMatcher.MatchIngredients(userIngredients, maxResults)
+func (s *EnhancedSearchService) AdvancedIngredientSearch(userIngredients []string, maxResults int, assumeStaples bool, opts MatchOptions) []RecipeMatchResult {
+	return s.ingredientMatcher.MatchIngredients(userIngredients, maxResults, assumeStaples, opts)
+}
+
+// CheckPantry reports which of recipe's ingredients the pantry covers
+func (s *EnhancedSearchService) CheckPantry(recipe *models.Recipe, pantry []string) PantryCheck {
+	return s.ingredientMatcher.CheckPantry(recipe, pantry)
 }
 
 // GetIngredientSubstitutes returns possible substitutes for a given ingredient
@@ -155,6 +204,21 @@ func (s *EnhancedSearchService) GetIngredientSynonyms(ingredient string) []strin
 	return s.ingredientMatcher.GetSynonyms(ingredient)
 }
 
+// IngredientSynonymsWithPrefix returns known ingredient synonyms starting with prefix
+func (s *EnhancedSearchService) IngredientSynonymsWithPrefix(prefix string) []string {
+	return s.ingredientMatcher.SynonymsWithPrefix(prefix)
+}
+
+// NormalizeIngredients returns the canonical form of each name, in order
+func (s *EnhancedSearchService) NormalizeIngredients(names []string) []NormalizedIngredient {
+	results := make([]NormalizedIngredient, len(names))
+	known := s.ingredientMatcher.KnownIngredients()
+	for i, name := range names {
+		results[i] = s.ingredientMatcher.Normalize(name, known)
+	}
+	return results
+}
+
 // AddIngredientSynonym allows adding custom synonyms at runtime
 func (s *EnhancedSearchService) AddIngredientSynonym(canonical, synonym string) {
 	s.ingredientMatcher.AddSynonym(canonical, synonym)
@@ -172,6 +236,27 @@ type SearchRequest struct {
 	MaxResults    int      `json:"max_results,omitempty"`   // limit results
 	MinMatchScore float64  `json:"min_match_score,omitempty"` // minimum score threshold
 	UseAdvanced   bool     `json:"use_advanced,omitempty"`   // use advanced matching
+	// MinRating drops recipes whose average rating is below it, or that have fewer
+	// than MinVotes ratings (default 1). Ignored when listing all recipes (no query
+	// and no ingredients).
+	MinRating float64 `json:"min_rating,omitempty"`
+	MinVotes  int     `json:"min_votes,omitempty"`
+	// AssumePantryStaples treats salt, pepper, water, oil etc. (MatchConfig.PantryStaples)
+	// as available, so recipes missing only those rank as complete. Advanced matching only.
+	AssumePantryStaples bool `json:"assume_pantry_staples,omitempty"`
+	// Fuzzy and Substitutes allow fuzzy (typo-tolerant) and substitute matches in
+	// advanced matching; both default to true. Set them to false for exact and
+	// synonym matches only.
+	Fuzzy       *bool `json:"fuzzy,omitempty"`
+	Substitutes *bool `json:"substitutes,omitempty"`
+}
+
+// matchOptions converts the request's match type flags for the matcher
+func (req SearchRequest) matchOptions() MatchOptions {
+	return MatchOptions{
+		DisableFuzzy:       req.Fuzzy != nil && !*req.Fuzzy,
+		DisableSubstitutes: req.Substitutes != nil && !*req.Substitutes,
+	}
 }
 
 // SearchResponse represents a comprehensive search response
@@ -183,19 +268,112 @@ type SearchResponse struct {
 	SearchType     string                `json:"search_type"`
 }
 
-// ComprehensiveSearch performs a comprehensive search based on the request
-func (s *EnhancedSearchService) ComprehensiveSearch(req SearchRequest) SearchResponse {
+// filterRated keeps the recipes in rated; a nil rated means no filtering
+func filterRated(recipes []*models.Recipe, rated map[int]bool) []*models.Recipe {
+	if rated == nil {
+		return recipes
+	}
+	filtered := make([]*models.Recipe, 0, len(recipes))
+	for _, rec := range recipes {
+		if rated[rec.ID] {
+			filtered = append(filtered, rec)
+		}
+	}
+	return filtered
+}
+
+// maxSearchResults caps SearchRequest.MaxResults so a client can't force a huge sort
+const maxSearchResults = 100
+
+// textRelevanceWeight is the share of CombinedScore taken by text relevance when a
+// search has both a query and ingredients; the ingredient match score gets the rest
+const textRelevanceWeight = 0.5
+
+// textRelevance scores how well a recipe matched a text query: a name starting with
+// the query ranks above one merely containing it, and both above a description match
+func textRelevance(recipe *models.Recipe, query string) float64 {
+	query = strings.ToLower(strings.TrimSpace(query))
+	name := strings.ToLower(recipe.Name)
+	switch {
+	case strings.HasPrefix(name, query):
+		return 1.0
+	case strings.Contains(name, query):
+		return 0.8
+	case strings.Contains(strings.ToLower(recipe.Description), query):
+		return 0.5
+	}
+	return 0
+}
+
+// ComprehensiveSearch performs a comprehensive search based on the request. It fails
+// only when the rating filter can't be loaded.
+func (s *EnhancedSearchService) ComprehensiveSearch(req SearchRequest) (SearchResponse, error) {
 	if req.MaxResults <= 0 {
 		req.MaxResults = 50
+	} else if req.MaxResults > maxSearchResults {
+		req.MaxResults = maxSearchResults
 	}
 
 	var response SearchResponse
 	response.Query = req.Query
 
+	// Recipes allowed by the rating filter; nil means no filter
+	var rated map[int]bool
+	if req.MinRating > 0 && (len(req.Ingredients) > 0 || req.Query != "") {
+		if req.MinVotes < 1 {
+			req.MinVotes = 1
+		}
+		ids, err := s.repo.RecipeIDsWithMinRating(req.MinRating, req.MinVotes)
+		if err != nil {
+			return SearchResponse{}, fmt.Errorf("load recipes rated %.1f+: %w", req.MinRating, err)
+		}
+		rated = ids
+	}
+
+	// A query together with ingredients narrows the ingredient results to recipes that
+	// also match the text; relevance holds their text scores, nil when not combining
+	var relevance map[int]float64
+	if req.Query != "" && len(req.Ingredients) > 0 {
+		relevance = make(map[int]float64)
+		for _, recipe := range s.SearchByName(req.Query) {
+			relevance[recipe.ID] = textRelevance(recipe, req.Query)
+		}
+	}
+
 	// Determine search type and perform appropriate search
 	if req.UseAdvanced && len(req.Ingredients) > 0 {
-		// Advanced ingredient matching
-		matches := s.AdvancedIngredientSearch(req.Ingredients, req.MaxResults)
+		// Advanced ingredient matching; filter by rating and text before applying the limit
+		limit := req.MaxResults
+		if rated != nil || relevance != nil {
+			limit = 0
+		}
+		matches := s.AdvancedIngredientSearch(req.Ingredients, limit, req.AssumePantryStaples, req.matchOptions())
+		if rated != nil {
+			filtered := make([]RecipeMatchResult, 0)
+			for _, match := range matches {
+				if rated[match.Recipe.ID] {
+					filtered = append(filtered, match)
+				}
+			}
+			matches = filtered
+		}
+		if relevance != nil {
+			filtered := make([]RecipeMatchResult, 0)
+			for _, match := range matches {
+				if rel, ok := relevance[match.Recipe.ID]; ok {
+					match.TextRelevance = rel
+					match.CombinedScore = textRelevanceWeight*rel + (1-textRelevanceWeight)*match.OverallScore
+					filtered = append(filtered, match)
+				}
+			}
+			sort.SliceStable(filtered, func(i, j int) bool {
+				return filtered[i].CombinedScore > filtered[j].CombinedScore
+			})
+			matches = filtered
+		}
+		if len(matches) > req.MaxResults {
+			matches = matches[:req.MaxResults]
+		}
 
 		// Filter by minimum score if specified
 		if req.MinMatchScore > 0 {
@@ -211,6 +389,9 @@ func (s *EnhancedSearchService) ComprehensiveSearch(req SearchRequest) SearchRes
 		response.AdvancedMatches = matches
 		response.TotalCount = len(matches)
 		response.SearchType = "advanced_ingredient"
+		if relevance != nil {
+			response.SearchType = "text_advanced_ingredient"
+		}
 		
 		// Also provide basic recipe list for compatibility
 		response.Recipes = make([]*models.Recipe, len(matches))
@@ -220,17 +401,31 @@ func (s *EnhancedSearchService) ComprehensiveSearch(req SearchRequest) SearchRes
 		
 	} else if len(req.Ingredients) > 0 {
 		// Basic ingredient search (exact match)
-		recipes := s.SearchByIngredients(req.Ingredients)
+		recipes := filterRated(s.SearchByIngredients(req.Ingredients), rated)
+		response.SearchType = "basic_ingredient"
+		if relevance != nil {
+			// Every recipe here contains all the ingredients, so rank by text relevance
+			filtered := make([]*models.Recipe, 0, len(recipes))
+			for _, recipe := range recipes {
+				if _, ok := relevance[recipe.ID]; ok {
+					filtered = append(filtered, recipe)
+				}
+			}
+			sort.SliceStable(filtered, func(i, j int) bool {
+				return relevance[filtered[i].ID] > relevance[filtered[j].ID]
+			})
+			recipes = filtered
+			response.SearchType = "text_basic_ingredient"
+		}
 		if len(recipes) > req.MaxResults {
 			recipes = recipes[:req.MaxResults]
 		}
 		response.Recipes = recipes
 		response.TotalCount = len(recipes)
-		response.SearchType = "basic_ingredient"
 		
 	} else if req.Query != "" {
 		// Text search
-		recipes := s.SearchByName(req.Query)
+		recipes := filterRated(s.SearchByName(req.Query), rated)
 		if len(recipes) > req.MaxResults {
 			recipes = recipes[:req.MaxResults]
 		}
@@ -239,15 +434,12 @@ func (s *EnhancedSearchService) ComprehensiveSearch(req SearchRequest) SearchRes
 		response.SearchType = "text"
 		
 	} else {
-		// Get all recipes
-		recipes := s.repo.GetAll()
-		if len(recipes) > req.MaxResults {
-			recipes = recipes[:req.MaxResults]
-		}
+		// Get all recipes, loading only the first MaxResults
+		recipes := s.repo.GetPaged(req.MaxResults, 0)
 		response.Recipes = recipes
 		response.TotalCount = len(recipes)
 		response.SearchType = "all"
 	}
 
-	return response
+	return response, nil
 }
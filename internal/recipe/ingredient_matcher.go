@@ -2,24 +2,79 @@ package recipe
 
 import (
 	"math"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"cooking-app/internal/models"
 )
 
+// recipeCacheTTL bounds how stale the matcher's recipe snapshot can get if a
+// change notification is dropped.
+const recipeCacheTTL = 5 * time.Minute
+
+// MatchConfig holds the scoring weights used by IngredientMatcher. Start from
+// DefaultMatchConfig and override individual fields.
+type MatchConfig struct {
+	// MatchThreshold is the minimum score (0-1) for a user ingredient to count as
+	// covering a recipe ingredient. Lower values accept weaker fuzzy/substitute matches.
+	MatchThreshold float64
+	// FuzzyThreshold is the minimum string similarity (0-1) for a fuzzy match. Raise it
+	// to cut false positives between similarly spelled ingredients.
+	FuzzyThreshold float64
+	// SynonymScore and SubstituteScore are the scores given to synonym and substitute
+	// matches (an exact match always scores 1).
+	SynonymScore    float64
+	SubstituteScore float64
+	// RecipeCoverageWeight weights the share of the recipe's ingredients the user has,
+	// i.e. how hard missing ingredients are penalised.
+	RecipeCoverageWeight float64
+	// PantryCoverageWeight weights the share of the user's ingredients the recipe uses,
+	// i.e. how hard extra (unused) ingredients are penalised. Set it to 0 for a
+	// "what can I cook" search where leftovers don't matter.
+	PantryCoverageWeight float64
+	// PantryStaples are ingredients assumed to be on hand when a search sets
+	// AssumePantryStaples: a recipe missing only these isn't penalised. Names are
+	// compared after synonym normalisation, so "sea salt" counts as "salt".
+	PantryStaples []string
+}
+
+// DefaultMatchConfig returns the standard matching weights.
+func DefaultMatchConfig() MatchConfig {
+	return MatchConfig{
+		MatchThreshold:       0.3,
+		FuzzyThreshold:       0.6,
+		SynonymScore:         0.9,
+		SubstituteScore:      0.7,
+		RecipeCoverageWeight: 0.7,
+		PantryCoverageWeight: 0.3,
+		PantryStaples:        []string{"salt", "pepper", "water", "oil", "olive oil", "vegetable oil"},
+	}
+}
+
 // IngredientMatcher provides advanced ingredient matching capabilities
 type IngredientMatcher struct {
 	repo        RecipeRepository
+	config      MatchConfig
+	staples     map[string]bool     // normalized config.PantryStaples
 	synonyms    map[string][]string // ingredient name -> list of synonyms
 	aliases     map[string]string   // alias -> canonical name
 	substitutes map[string][]string // ingredient -> possible substitutes
+
+	cacheMu  sync.RWMutex
+	recipes  []*models.Recipe // snapshot of repo.GetAll(), nil when invalidated
+	cachedAt time.Time
+	cacheGen uint64 // bumped by InvalidateCache; a load started before a bump isn't stored
 }
 
 // NewIngredientMatcher creates a new ingredient matcher with predefined data
-func NewIngredientMatcher(repo RecipeRepository) *IngredientMatcher {
+func NewIngredientMatcher(repo RecipeRepository, config MatchConfig) *IngredientMatcher {
 	im := &IngredientMatcher{
 		repo:        repo,
+		config:      config,
 		synonyms:    make(map[string][]string),
 		aliases:     make(map[string]string),
 		substitutes: make(map[string][]string),
@@ -27,6 +82,13 @@ func NewIngredientMatcher(repo RecipeRepository) *IngredientMatcher {
 
 	// Initialize ingredient synonyms and aliases
 	im.initializeIngredientData()
+
+	im.staples = make(map[string]bool)
+	for _, staple := range config.PantryStaples {
+		if name := im.normalizeIngredientName(staple); name != "" {
+			im.staples[name] = true
+		}
+	}
 	return im
 }
 
@@ -100,6 +162,41 @@ func (im *IngredientMatcher) initializeIngredientData() {
 	}
 }
 
+// allRecipes returns the cached recipe snapshot, reloading it from the repository
+// when it has been invalidated or is older than recipeCacheTTL.
+func (im *IngredientMatcher) allRecipes() []*models.Recipe {
+	im.cacheMu.RLock()
+	if im.recipes != nil && time.Since(im.cachedAt) < recipeCacheTTL {
+		recipes := im.recipes
+		im.cacheMu.RUnlock()
+		return recipes
+	}
+	gen := im.cacheGen
+	im.cacheMu.RUnlock()
+
+	recipes := im.repo.GetAll()
+	if recipes == nil {
+		recipes = []*models.Recipe{}
+	}
+	im.cacheMu.Lock()
+	// A change invalidated the cache while we loaded: our snapshot may predate it, so
+	// use it for this search only and let the next one reload.
+	if im.cacheGen == gen {
+		im.recipes = recipes
+		im.cachedAt = time.Now()
+	}
+	im.cacheMu.Unlock()
+	return recipes
+}
+
+// InvalidateCache drops the recipe snapshot so the next search reloads it.
+func (im *IngredientMatcher) InvalidateCache() {
+	im.cacheMu.Lock()
+	im.recipes = nil
+	im.cacheGen++
+	im.cacheMu.Unlock()
+}
+
 // normalizeIngredientName returns the canonical form of an ingredient name
 func (im *IngredientMatcher) normalizeIngredientName(name string) string {
 	name = strings.ToLower(strings.TrimSpace(name))
@@ -121,9 +218,82 @@ func (im *IngredientMatcher) normalizeIngredientName(name string) string {
 	return name
 }
 
-// levenshteinDistance calculates the edit distance between two strings
-func (im *IngredientMatcher) levenshteinDistance(a, b string) int {
-	a, b = strings.ToLower(a), strings.ToLower(b)
+// NormalizedIngredient is the canonical form the matcher uses for an input name.
+type NormalizedIngredient struct {
+	Input     string  `json:"input"`
+	Canonical string  `json:"canonical"`
+	MatchType string  `json:"match_type"` // "exact", "alias", "synonym", "fuzzy" or "none"
+	Score     float64 `json:"score"`
+}
+
+// Normalize maps name to its canonical form and reports how it was found: an exact
+// known ingredient, an alias, a synonym, or a fuzzy match (more than FuzzyThreshold
+// similar, as in findBestMatch) against known, the names from KnownIngredients.
+// Unrecognised names come back lowercased with MatchType "none".
+func (im *IngredientMatcher) Normalize(name string, known map[string]bool) NormalizedIngredient {
+	result := NormalizedIngredient{Input: name, MatchType: "none"}
+	lower := strings.ToLower(strings.TrimSpace(name))
+	result.Canonical = lower
+	if lower == "" {
+		return result
+	}
+
+	if known[lower] {
+		result.MatchType, result.Score = "exact", 1.0
+		return result
+	}
+	if canonical, ok := im.aliases[lower]; ok {
+		result.Canonical, result.MatchType, result.Score = canonical, "alias", 1.0
+		return result
+	}
+	if canonical := im.normalizeIngredientName(lower); canonical != lower {
+		result.Canonical, result.MatchType, result.Score = canonical, "synonym", im.config.SynonymScore
+		return result
+	}
+
+	best, bestScore := "", 0.0
+	for candidate := range known {
+		score := im.similarityScore(lower, candidate)
+		// Ties go to the alphabetically first name so results are stable.
+		if score > bestScore || (score == bestScore && candidate < best) {
+			best, bestScore = candidate, score
+		}
+	}
+	if bestScore > im.config.FuzzyThreshold {
+		result.Canonical = im.normalizeIngredientName(best)
+		result.MatchType, result.Score = "fuzzy", bestScore
+	}
+	return result
+}
+
+// KnownIngredients returns the canonical names the matcher knows: those in its
+// synonym and substitute data plus every ingredient used by a recipe. It reads every
+// recipe, so build it once per batch of Normalize calls.
+func (im *IngredientMatcher) KnownIngredients() map[string]bool {
+	known := make(map[string]bool)
+	for canonical := range im.synonyms {
+		known[canonical] = true
+	}
+	for _, canonical := range im.aliases {
+		known[canonical] = true
+	}
+	for ingredient := range im.substitutes {
+		known[ingredient] = true
+	}
+	for _, rec := range im.allRecipes() {
+		for _, ri := range rec.Ingredients {
+			if name := im.normalizeIngredientName(ri.Ingredient.Name); name != "" {
+				known[name] = true
+			}
+		}
+	}
+	return known
+}
+
+// levenshteinDistance calculates the edit distance between two strings, counting
+// runes rather than bytes so "jalapeño" vs "jalapeno" is one edit
+func (im *IngredientMatcher) levenshteinDistance(sa, sb string) int {
+	a, b := []rune(strings.ToLower(sa)), []rune(strings.ToLower(sb))
 	if len(a) == 0 {
 		return len(b)
 	}
@@ -163,17 +333,22 @@ func (im *IngredientMatcher) similarityScore(a, b string) float64 {
 		return 1.0
 	}
 
-	// Check if one contains the other
-	if strings.Contains(a, b) || strings.Contains(b, a) {
-		shorter, longer := a, b
-		if len(a) > len(b) {
-			shorter, longer = b, a
-		}
-		return float64(len(shorter)) / float64(len(longer))
+	// Lengths are in runes so accented names aren't penalised for multi-byte characters
+	lenA, lenB := utf8.RuneCountInString(a), utf8.RuneCountInString(b)
+
+	// Check if one contains the other as whole words ("egg" in "egg yolk", not "eggplant")
+	shorter, longer := a, b
+	shortLen, longLen := lenA, lenB
+	if lenA > lenB {
+		shorter, longer = b, a
+		shortLen, longLen = lenB, lenA
+	}
+	if im.containsWords(longer, shorter) {
+		return float64(shortLen) / float64(longLen)
 	}
 
 	// Levenshtein distance similarity
-	maxLen := math.Max(float64(len(a)), float64(len(b)))
+	maxLen := math.Max(float64(lenA), float64(lenB))
 	if maxLen == 0 {
 		return 1.0
 	}
@@ -184,6 +359,29 @@ func (im *IngredientMatcher) similarityScore(a, b string) float64 {
 	return math.Max(0, similarity)
 }
 
+// containsWords reports whether every word of sub appears in text as a whole word,
+// allowing a plain plural ("egg" matches "eggs" and "tomato" matches "tomatoes").
+func (im *IngredientMatcher) containsWords(text, sub string) bool {
+	subWords := im.tokenize(sub)
+	if len(subWords) == 0 {
+		return false
+	}
+	textWords := im.tokenize(text)
+	for _, sw := range subWords {
+		found := false
+		for _, tw := range textWords {
+			if tw == sw || tw == sw+"s" || tw == sw+"es" || sw == tw+"s" || sw == tw+"es" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 // MatchResult represents a single ingredient match with its score
 type MatchResult struct {
 	Ingredient string  `json:"ingredient"`
@@ -199,10 +397,22 @@ type RecipeMatchResult struct {
 	MatchDetails []MatchResult  `json:"match_details"`
 	MissingCount int            `json:"missing_count"`
 	ExtraCount   int            `json:"extra_count"`
+	// TextRelevance and CombinedScore are set when a search also has a text query;
+	// results are then ranked by CombinedScore instead of OverallScore.
+	TextRelevance float64 `json:"text_relevance,omitempty"`
+	CombinedScore float64 `json:"combined_score,omitempty"`
 }
 
-// MatchIngredients performs advanced ingredient matching against all recipes
-func (im *IngredientMatcher) MatchIngredients(userIngredients []string, maxResults int) []RecipeMatchResult {
+// MatchOptions narrows the match types findBestMatch may use for one call. The zero
+// value allows every type.
+type MatchOptions struct {
+	DisableFuzzy       bool // skip fuzzy (edit distance) matches
+	DisableSubstitutes bool // skip substitute matches
+}
+
+// MatchIngredients performs advanced ingredient matching against all recipes. With
+// assumeStaples, unmatched pantry staples don't count as missing.
+func (im *IngredientMatcher) MatchIngredients(userIngredients []string, maxResults int, assumeStaples bool, opts MatchOptions) []RecipeMatchResult {
 	// Normalize user ingredients
 	normalizedUser := make(map[string]bool)
 	for _, ing := range userIngredients {
@@ -216,25 +426,25 @@ func (im *IngredientMatcher) MatchIngredients(userIngredients []string, maxResul
 		return []RecipeMatchResult{}
 	}
 
-	// Get all recipes
-	recipes := im.repo.GetAll()
+	// Get all recipes (cached snapshot)
+	recipes := im.allRecipes()
 	var results []RecipeMatchResult
 
 	for _, recipe := range recipes {
-		matchResult := im.calculateRecipeMatch(recipe, normalizedUser, userIngredients)
+		matchResult := im.calculateRecipeMatch(recipe, normalizedUser, userIngredients, assumeStaples, opts)
 		if matchResult.OverallScore > 0 {
 			results = append(results, matchResult)
 		}
 	}
 
-	// Sort by overall score (descending)
-	for i := 0; i < len(results)-1; i++ {
-		for j := i + 1; j < len(results); j++ {
-			if results[j].OverallScore > results[i].OverallScore {
-				results[i], results[j] = results[j], results[i]
-			}
+	// Sort by overall score (descending), then recipe ID (ascending) so equal scores
+	// always come back in the same order.
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].OverallScore != results[j].OverallScore {
+			return results[i].OverallScore > results[j].OverallScore
 		}
-	}
+		return results[i].Recipe.ID < results[j].Recipe.ID
+	})
 
 	// Limit results
 	if maxResults > 0 && len(results) > maxResults {
@@ -245,29 +455,32 @@ func (im *IngredientMatcher) MatchIngredients(userIngredients []string, maxResul
 }
 
 // calculateRecipeMatch calculates how well a recipe matches the user's ingredients
-func (im *IngredientMatcher) calculateRecipeMatch(recipe *models.Recipe, userIngredients map[string]bool, originalUserIngredients []string) RecipeMatchResult {
+func (im *IngredientMatcher) calculateRecipeMatch(recipe *models.Recipe, userIngredients map[string]bool, originalUserIngredients []string, assumeStaples bool, opts MatchOptions) RecipeMatchResult {
 	var matchDetails []MatchResult
 	matchedIngredients := make(map[string]bool)
+	assumedStaples := 0
 
 	// Match each recipe ingredient against user ingredients
 	for _, recipeIng := range recipe.Ingredients {
 		recipeIngName := im.normalizeIngredientName(recipeIng.Ingredient.Name)
 
 		// Use original user ingredients for findBestMatch (it will normalize internally)
-		bestMatch := im.findBestMatch(recipeIngName, originalUserIngredients)
-		if bestMatch.Score > 0.3 { // Threshold for considering it a match
+		bestMatch := im.findBestMatch(recipeIngName, originalUserIngredients, opts)
+		if bestMatch.Score > im.config.MatchThreshold {
 			matchDetails = append(matchDetails, bestMatch)
 			matchedIngredients[recipeIngName] = true
+		} else if assumeStaples && im.staples[recipeIngName] {
+			assumedStaples++
 		}
 	}
 
-	// Calculate basic counts
-	totalRecipeIngredients := len(recipe.Ingredients)
+	// Calculate basic counts; assumed staples are neither matched nor missing
+	totalRecipeIngredients := len(recipe.Ingredients) - assumedStaples
 	matchedCount := len(matchedIngredients)
 	missingCount := totalRecipeIngredients - matchedCount
 
-	// Skip recipes with no ingredients
-	if totalRecipeIngredients == 0 {
+	// Skip recipes with no ingredients, or nothing from the user's list
+	if totalRecipeIngredients <= 0 || matchedCount == 0 {
 		return RecipeMatchResult{
 			Recipe:       recipe,
 			OverallScore: 0,
@@ -284,7 +497,6 @@ func (im *IngredientMatcher) calculateRecipeMatch(recipe *models.Recipe, userIng
 		extraCount = 0
 	}
 
-	// Overall score calculation (same as working commit e2aaf92)
 	// Blend coverageRecipe (how much of the recipe the user can make) and
 	// coverageUser (how much of the user's pantry is used by the recipe).
 	coverageRecipe := float64(matchedCount) / float64(totalRecipeIngredients)
@@ -292,7 +504,11 @@ func (im *IngredientMatcher) calculateRecipeMatch(recipe *models.Recipe, userIng
 	if len(userIngredients) > 0 {
 		coverageUser = float64(matchedCount) / float64(len(userIngredients))
 	}
-	overallScore := 0.7*coverageRecipe + 0.3*coverageUser
+	weightSum := im.config.RecipeCoverageWeight + im.config.PantryCoverageWeight
+	overallScore := 0.0
+	if weightSum > 0 {
+		overallScore = (im.config.RecipeCoverageWeight*coverageRecipe + im.config.PantryCoverageWeight*coverageUser) / weightSum
+	}
 	if overallScore < 0 {
 		overallScore = 0
 	} else if overallScore > 1 {
@@ -308,8 +524,48 @@ func (im *IngredientMatcher) calculateRecipeMatch(recipe *models.Recipe, userIng
 	}
 }
 
-// findBestMatch finds the best matching user ingredient for a recipe ingredient
-func (im *IngredientMatcher) findBestMatch(recipeIngredient string, userIngredients []string) MatchResult {
+// PantryItem reports whether one recipe ingredient is covered by the pantry.
+type PantryItem struct {
+	Name      string `json:"name"`
+	Have      bool   `json:"have"`
+	MatchedAs string `json:"matched_as,omitempty"` // pantry entry that covers it
+	MatchType string `json:"match_type,omitempty"` // "exact", "synonym", "fuzzy", "substitute"
+}
+
+// PantryCheck is the result of CheckPantry for one recipe.
+type PantryCheck struct {
+	RecipeID     int          `json:"recipe_id"`
+	Makeable     bool         `json:"makeable"` // every ingredient is covered
+	MissingCount int          `json:"missing_count"`
+	Ingredients  []PantryItem `json:"ingredients"`
+}
+
+// CheckPantry matches each of recipe's ingredients against pantry with the same
+// rules and threshold as MatchIngredients, reporting which are missing.
+func (im *IngredientMatcher) CheckPantry(recipe *models.Recipe, pantry []string) PantryCheck {
+	check := PantryCheck{
+		RecipeID:    recipe.ID,
+		Ingredients: make([]PantryItem, 0, len(recipe.Ingredients)),
+	}
+	for _, recipeIng := range recipe.Ingredients {
+		item := PantryItem{Name: recipeIng.Ingredient.Name}
+		best := im.findBestMatch(im.normalizeIngredientName(recipeIng.Ingredient.Name), pantry, MatchOptions{})
+		if best.Score > im.config.MatchThreshold {
+			item.Have = true
+			item.MatchedAs = best.Original
+			item.MatchType = best.MatchType
+		} else {
+			check.MissingCount++
+		}
+		check.Ingredients = append(check.Ingredients, item)
+	}
+	check.Makeable = check.MissingCount == 0
+	return check
+}
+
+// findBestMatch finds the best matching user ingredient for a recipe ingredient,
+// using only the match types opts allows
+func (im *IngredientMatcher) findBestMatch(recipeIngredient string, userIngredients []string, opts MatchOptions) MatchResult {
 	bestMatch := MatchResult{
 		Score: 0, // Initialize with 0 score
 	}
@@ -331,15 +587,15 @@ func (im *IngredientMatcher) findBestMatch(recipeIngredient string, userIngredie
 		if im.isSynonym(normalizedUser, recipeIngredient) {
 			return MatchResult{
 				Ingredient: recipeIngredient,
-				Score:      0.9,
+				Score:      im.config.SynonymScore,
 				MatchType:  "synonym",
 				Original:   userIng,
 			}
 		}
 
 		// Check substitute match
-		if im.isSubstitute(normalizedUser, recipeIngredient) {
-			score := 0.7
+		if !opts.DisableSubstitutes && im.isSubstitute(normalizedUser, recipeIngredient) {
+			score := im.config.SubstituteScore
 			if score > bestMatch.Score {
 				bestMatch = MatchResult{
 					Ingredient: recipeIngredient,
@@ -351,8 +607,11 @@ func (im *IngredientMatcher) findBestMatch(recipeIngredient string, userIngredie
 		}
 
 		// Check fuzzy match
+		if opts.DisableFuzzy {
+			continue
+		}
 		similarity := im.similarityScore(normalizedUser, recipeIngredient)
-		if similarity > 0.6 && similarity > bestMatch.Score {
+		if similarity > im.config.FuzzyThreshold && similarity > bestMatch.Score {
 			bestMatch = MatchResult{
 				Ingredient: recipeIngredient,
 				Score:      similarity,
@@ -434,6 +693,36 @@ func (im *IngredientMatcher) GetSynonyms(ingredient string) []string {
 	return []string{}
 }
 
+// SynonymsWithPrefix returns known synonyms and aliases starting with prefix
+// (case-insensitive), sorted and deduplicated
+func (im *IngredientMatcher) SynonymsWithPrefix(prefix string) []string {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if prefix == "" {
+		return []string{}
+	}
+
+	seen := make(map[string]bool)
+	for _, synonyms := range im.synonyms {
+		for _, synonym := range synonyms {
+			if strings.HasPrefix(synonym, prefix) {
+				seen[synonym] = true
+			}
+		}
+	}
+	for alias := range im.aliases {
+		if strings.HasPrefix(alias, prefix) {
+			seen[alias] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // AddSynonym allows adding custom synonyms at runtime
 func (im *IngredientMatcher) AddSynonym(canonical, synonym string) {
 	canonical = im.normalizeIngredientName(canonical)
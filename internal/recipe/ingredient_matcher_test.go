@@ -0,0 +1,65 @@
+package recipe
+
+import (
+	"testing"
+
+	"cooking-app/internal/models"
+)
+
+func TestLevenshteinDistance(t *testing.T) {
+	im := &IngredientMatcher{}
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"jalapeño", "jalapeno", 1},
+		{"crème", "creme", 1},
+		{"crème fraîche", "creme fraiche", 2},
+		{"Jalapeño", "jalapeño", 0}, // case-insensitive
+		{"piñata", "pinata", 1},
+		{"ñ", "", 1},
+		{"", "crème", 5},
+		{"tomato", "potato", 2},
+		{"egg", "egg", 0},
+	}
+	for _, tt := range tests {
+		if got := im.levenshteinDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshteinDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := im.levenshteinDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("levenshteinDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+// loadingRepo serves GetAll from a counter and runs during, if set, while loading.
+type loadingRepo struct {
+	RecipeRepository
+	loads  int
+	during func()
+}
+
+func (r *loadingRepo) GetAll() []*models.Recipe {
+	r.loads++
+	if r.during != nil {
+		r.during()
+	}
+	return []*models.Recipe{{ID: r.loads}}
+}
+
+func TestAllRecipesKeepsInvalidationDuringLoad(t *testing.T) {
+	repo := &loadingRepo{}
+	im := NewIngredientMatcher(repo, DefaultMatchConfig())
+
+	// A recipe changes while the first snapshot is being read.
+	repo.during = im.InvalidateCache
+	im.allRecipes()
+	repo.during = nil
+
+	if got := im.allRecipes(); repo.loads != 2 || got[0].ID != 2 {
+		t.Fatalf("after invalidation during load: %d loads, snapshot %d; want a reload", repo.loads, got[0].ID)
+	}
+	if im.allRecipes(); repo.loads != 2 {
+		t.Errorf("%d loads, want the second snapshot cached", repo.loads)
+	}
+}
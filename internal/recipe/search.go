@@ -59,15 +59,8 @@ func (s *SearchService) reindexRecipe(recipeID int) {
 	}
 
 	// Add keywords from recipe name and description
-	text := strings.ToLower(recipe.Name + " " + recipe.Description)
-	words := strings.Fields(text)
-	seen := make(map[string]bool)
-	for _, w := range words {
-		w = strings.Trim(w, ".,!?")
-		if len(w) >= 2 && !seen[w] {
-			seen[w] = true
-			s.index[w] = append(s.index[w], recipeID)
-		}
+	for _, w := range searchTerms(recipe.Name + " " + recipe.Description) {
+		s.index[w] = append(s.index[w], recipeID)
 	}
 }
 
@@ -77,19 +70,27 @@ func (s *SearchService) rebuildIndex() {
 	defer s.mu.Unlock()
 	s.index = make(map[string][]int)
 	for _, recipe := range recipes {
-		text := strings.ToLower(recipe.Name + " " + recipe.Description)
-		words := strings.Fields(text)
-		seen := make(map[string]bool)
-		for _, w := range words {
-			w = strings.Trim(w, ".,!?")
-			if len(w) >= 2 && !seen[w] {
-				seen[w] = true
-				s.index[w] = append(s.index[w], recipe.ID)
-			}
+		for _, w := range searchTerms(recipe.Name + " " + recipe.Description) {
+			s.index[w] = append(s.index[w], recipe.ID)
 		}
 	}
 }
 
+// searchTerms splits text into the lowercase keywords the index uses: words of at
+// least two characters with surrounding punctuation trimmed, each listed once.
+func searchTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, w := range strings.Fields(strings.ToLower(text)) {
+		w = strings.Trim(w, ".,!?")
+		if len(w) >= 2 && !seen[w] {
+			seen[w] = true
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
 // NotifyRecipeChange notifies the indexer that a recipe was added or updated (async via channel)
 func (s *SearchService) NotifyRecipeChange(recipeID int) {
 	select {
@@ -104,7 +105,40 @@ func (s *SearchService) SearchByName(query string) []*models.Recipe {
 	return s.repo.SearchByName(query)
 }
 
-// SearchByIngredients returns recipes that contain all given ingredients
-func (s *SearchService) SearchByIngredients(names []string) []*models.Recipe {
-	return s.repo.SearchByIngredients(names)
+// Explain reports which terms of query appear in each recipe's name and description,
+// for showing why a search result matched. A query with no keywords is treated as a
+// single term.
+func (s *SearchService) Explain(recipes []*models.Recipe, query string) []models.RecipeSearchResult {
+	terms := searchTerms(query)
+	if q := strings.ToLower(strings.TrimSpace(query)); len(terms) == 0 && q != "" {
+		terms = []string{q}
+	}
+
+	results := make([]models.RecipeSearchResult, len(recipes))
+	for i, recipe := range recipes {
+		results[i] = models.RecipeSearchResult{Recipe: recipe, MatchedFields: []models.MatchedField{}}
+		fields := []struct{ name, text string }{
+			{"name", recipe.Name},
+			{"description", recipe.Description},
+		}
+		for _, f := range fields {
+			text := strings.ToLower(f.text)
+			var matched []string
+			for _, term := range terms {
+				if strings.Contains(text, term) {
+					matched = append(matched, term)
+				}
+			}
+			if len(matched) > 0 {
+				results[i].MatchedFields = append(results[i].MatchedFields, models.MatchedField{Field: f.name, Terms: matched})
+			}
+		}
+	}
+	return results
+}
+
+// SearchByIngredients returns recipes that contain all given ingredients, or any of
+// them with matchAny
+func (s *SearchService) SearchByIngredients(names []string, matchAny bool) []*models.Recipe {
+	return s.repo.SearchByIngredients(names, matchAny)
 }
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"sync"
+
+	"cooking-app/internal/models"
+)
+
+// commentSubscriberBuffer is how many comments a subscriber may fall behind before
+// further comments are dropped for it.
+const commentSubscriberBuffer = 16
+
+// CommentBroker fans newly created comments out to in-process subscribers, keyed
+// by recipe ID. It backs the comment stream endpoint.
+type CommentBroker struct {
+	mu     sync.Mutex
+	subs   map[int]map[chan *models.Comment]struct{}
+	closed bool // set by Close; later subscribers get an already closed channel
+}
+
+// NewCommentBroker creates an empty broker.
+func NewCommentBroker() *CommentBroker {
+	return &CommentBroker{subs: make(map[int]map[chan *models.Comment]struct{})}
+}
+
+// Subscribe returns a channel receiving comments posted on recipeID from now on, and
+// a cancel function that must be called when the subscriber goes away. The channel
+// is closed by cancel, or earlier by Close.
+func (b *CommentBroker) Subscribe(recipeID int) (<-chan *models.Comment, func()) {
+	ch := make(chan *models.Comment, commentSubscriberBuffer)
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subs[recipeID] == nil {
+		b.subs[recipeID] = make(map[chan *models.Comment]struct{})
+	}
+	b.subs[recipeID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subs[recipeID][ch]; !ok {
+				return // already closed by Close
+			}
+			delete(b.subs[recipeID], ch)
+			if len(b.subs[recipeID]) == 0 {
+				delete(b.subs, recipeID)
+			}
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+// Publish delivers c to every subscriber of its recipe without blocking; a
+// subscriber whose buffer is full misses it.
+func (b *CommentBroker) Publish(c *models.Comment) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[c.RecipeID] {
+		select {
+		case ch <- c:
+		default:
+		}
+	}
+}
+
+// Close closes every subscriber channel so open comment streams end, e.g. when the
+// server shuts down. Later Subscribe calls get a closed channel; Publish becomes a no-op.
+func (b *CommentBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, chans := range b.subs {
+		for ch := range chans {
+			close(ch)
+		}
+	}
+	clear(b.subs)
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrCommentRejected is returned when a CommentModerator refuses a comment.
+var ErrCommentRejected = errors.New("comment rejected by moderation")
+
+// CommentModerator vets comment content before it is stored. Check returns an error
+// wrapping ErrCommentRejected for content that must not be posted; any other error
+// is treated as a failure of the moderator itself.
+type CommentModerator interface {
+	Check(content string) error
+}
+
+// BlocklistModerator rejects comments containing any blocked word. Words are matched
+// case-insensitively against whole words only, so "class" doesn't match "ass".
+type BlocklistModerator struct {
+	blocked map[string]bool
+}
+
+// NewBlocklistModerator creates a moderator for the given words. An empty list accepts everything.
+func NewBlocklistModerator(words []string) *BlocklistModerator {
+	m := &BlocklistModerator{blocked: make(map[string]bool)}
+	for _, w := range words {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			m.blocked[w] = true
+		}
+	}
+	return m
+}
+
+// Check implements CommentModerator.
+func (m *BlocklistModerator) Check(content string) error {
+	if len(m.blocked) == 0 {
+		return nil
+	}
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if m.blocked[w] {
+			return ErrCommentRejected
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"cooking-app/internal/models"
+)
+
+var ErrFavoriteNotFound = errors.New("favorite not found")
+
+// FavoriteRepository stores user recipe bookmarks in PostgreSQL.
+type FavoriteRepository struct {
+	db *sql.DB
+}
+
+// NewFavoriteRepository creates a new repository backed by PostgreSQL.
+func NewFavoriteRepository(db *sql.DB) *FavoriteRepository {
+	return &FavoriteRepository{db: db}
+}
+
+// Add saves a recipe to the user's favorites. Adding an existing favorite is a no-op;
+// created reports whether a new row was inserted.
+func (r *FavoriteRepository) Add(userID, recipeID int) (fav *models.UserFavorite, created bool, err error) {
+	fav = &models.UserFavorite{UserID: userID, RecipeID: recipeID}
+	err = r.db.QueryRow(`
+		INSERT INTO favorites (user_id, recipe_id, saved_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id, recipe_id) DO NOTHING
+		RETURNING saved_at`, userID, recipeID).Scan(&fav.SavedAt)
+	if err == nil {
+		return fav, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	// Already favorited: return the existing row.
+	err = r.db.QueryRow(`SELECT saved_at FROM favorites WHERE user_id = $1 AND recipe_id = $2`,
+		userID, recipeID).Scan(&fav.SavedAt)
+	if err != nil {
+		return nil, false, err
+	}
+	return fav, false, nil
+}
+
+// Remove deletes a recipe from the user's favorites.
+func (r *FavoriteRepository) Remove(userID, recipeID int) error {
+	res, err := r.db.Exec("DELETE FROM favorites WHERE user_id = $1 AND recipe_id = $2", userID, recipeID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return ErrFavoriteNotFound
+	}
+	return nil
+}
+
+// IsFavorite reports whether the user has saved the recipe.
+func (r *FavoriteRepository) IsFavorite(userID, recipeID int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM favorites WHERE user_id = $1 AND recipe_id = $2)`,
+		userID, recipeID).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// ListByUser returns the user's favorites, most recently saved first.
+func (r *FavoriteRepository) ListByUser(userID int) ([]*models.UserFavorite, error) {
+	rows, err := r.db.Query(`
+		SELECT user_id, recipe_id, saved_at
+		FROM favorites
+		WHERE user_id = $1
+		ORDER BY saved_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var favorites []*models.UserFavorite
+	for rows.Next() {
+		var fav models.UserFavorite
+		if err := rows.Scan(&fav.UserID, &fav.RecipeID, &fav.SavedAt); err != nil {
+			return nil, err
+		}
+		favorites = append(favorites, &fav)
+	}
+	return favorites, rows.Err()
+}
@@ -2,11 +2,16 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"unicode"
 
 	"cooking-app/internal/models"
 )
 
+var ErrIngredientNotFound = errors.New("ingredient not found")
+
 // IngredientRepository manages ingredients in the database.
 type IngredientRepository struct {
 	db *sql.DB
@@ -17,27 +22,42 @@ func NewIngredientRepository(db *sql.DB) *IngredientRepository {
 	return &IngredientRepository{db: db}
 }
 
+// NormalizeIngredientName trims the name, collapses inner whitespace and title-cases
+// each word, so "  olive  oil " becomes "Olive Oil".
+func NormalizeIngredientName(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		runes := []rune(strings.ToLower(w))
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
+
 // CreateIngredient creates a new ingredient if it doesn't exist.
 func (r *IngredientRepository) CreateIngredient(name string) (*models.Ingredient, error) {
-	// Check if ingredient already exists
-	var existingID int
-	err := r.db.QueryRow("SELECT id FROM ingredients WHERE LOWER(name) = LOWER($1)", name).Scan(&existingID)
+	ing, _, err := r.GetOrCreate(name)
+	return ing, err
+}
+
+// GetOrCreate returns the ingredient matching name (case-insensitive), inserting it
+// when missing; created reports whether a new row was inserted.
+func (r *IngredientRepository) GetOrCreate(name string) (ing *models.Ingredient, created bool, err error) {
+	ing = &models.Ingredient{}
+	err = r.db.QueryRow("SELECT id, name FROM ingredients WHERE LOWER(name) = LOWER($1)", name).Scan(&ing.ID, &ing.Name)
 	if err == nil {
-		// Ingredient exists
-		return &models.Ingredient{ID: existingID, Name: name}, nil
+		return ing, false, nil
 	}
 	if err != sql.ErrNoRows {
-		return nil, err
+		return nil, false, err
 	}
 
-	// Insert new ingredient
-	var id int
-	err = r.db.QueryRow("INSERT INTO ingredients (name) VALUES ($1) RETURNING id", name).Scan(&id)
+	ing.Name = name
+	err = r.db.QueryRow("INSERT INTO ingredients (name) VALUES ($1) RETURNING id", name).Scan(&ing.ID)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-
-	return &models.Ingredient{ID: id, Name: name}, nil
+	return ing, true, nil
 }
 
 // InitializeIngredients adds common ingredients to the database.
@@ -95,6 +115,54 @@ func (r *IngredientRepository) GetIngredientByName(name string) (*models.Ingredi
 	return &ing, nil
 }
 
+// SearchByPrefix returns up to limit ingredients whose name starts with prefix
+// (case-insensitive), ordered by name.
+func (r *IngredientRepository) SearchByPrefix(prefix string, limit int) ([]models.Ingredient, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+	rows, err := r.db.Query(`SELECT id, name FROM ingredients WHERE name ILIKE $1 ORDER BY name LIMIT $2`,
+		escaped+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ingredients []models.Ingredient
+	for rows.Next() {
+		var ing models.Ingredient
+		if err := rows.Scan(&ing.ID, &ing.Name); err != nil {
+			return nil, err
+		}
+		ingredients = append(ingredients, ing)
+	}
+	return ingredients, rows.Err()
+}
+
+// GetPopular returns the limit ingredients used by the most (non-deleted) recipes.
+func (r *IngredientRepository) GetPopular(limit int) ([]models.IngredientUsage, error) {
+	rows, err := r.db.Query(`
+		SELECT i.id, i.name, COUNT(*) AS recipe_count
+		FROM ingredients i
+		JOIN recipe_ingredients ri ON ri.ingredient_id = i.id
+		JOIN recipes rec ON rec.id = ri.recipe_id AND rec.deleted_at IS NULL
+		GROUP BY i.id, i.name
+		ORDER BY recipe_count DESC, i.name
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []models.IngredientUsage
+	for rows.Next() {
+		var u models.IngredientUsage
+		if err := rows.Scan(&u.ID, &u.Name, &u.RecipeCount); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
 // GetAllIngredients returns all ingredients.
 func (r *IngredientRepository) GetAllIngredients() ([]models.Ingredient, error) {
 	rows, err := r.db.Query("SELECT id, name FROM ingredients ORDER BY name")
@@ -113,3 +181,96 @@ func (r *IngredientRepository) GetAllIngredients() ([]models.Ingredient, error)
 	}
 	return ingredients, nil
 }
+
+// MergeIngredients folds ingredient fromID into toID in one transaction: recipe links
+// and saved recipe versions are repointed to toID and fromID is deleted. A recipe (or
+// version) that already lists toID keeps that entry and loses its fromID one, since a
+// recipe can list an ingredient only once. Affected recipes get a new updated_at.
+// Returns ErrIngredientNotFound if either ID doesn't exist.
+func (r *IngredientRepository) MergeIngredients(fromID, toID int) (*models.IngredientMergeResult, error) {
+	if fromID == toID {
+		return nil, errors.New("cannot merge an ingredient into itself")
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &models.IngredientMergeResult{RecipeIDs: []int{}}
+	if err := lockIngredient(tx, fromID, &result.Merged); err != nil {
+		return nil, err
+	}
+	if err := lockIngredient(tx, toID, &result.Into); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`SELECT recipe_id FROM recipe_ingredients WHERE ingredient_id = $1 ORDER BY recipe_id`, fromID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.RecipeIDs = append(result.RecipeIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Drop links that would collide with the recipe's existing row for toID.
+	res, err := tx.Exec(`DELETE FROM recipe_ingredients f
+		WHERE f.ingredient_id = $1 AND EXISTS (
+			SELECT 1 FROM recipe_ingredients t WHERE t.recipe_id = f.recipe_id AND t.ingredient_id = $2
+		)`, fromID, toID)
+	if err != nil {
+		return nil, fmt.Errorf("drop duplicate links: %w", err)
+	}
+	dropped, _ := res.RowsAffected()
+	result.Dropped = int(dropped)
+
+	res, err = tx.Exec(`UPDATE recipe_ingredients SET ingredient_id = $2 WHERE ingredient_id = $1`, fromID, toID)
+	if err != nil {
+		return nil, fmt.Errorf("relink recipes: %w", err)
+	}
+	relinked, _ := res.RowsAffected()
+	result.Relinked = int(relinked)
+
+	// Rewrite saved versions too, so reverting to one still finds its ingredients.
+	if _, err := tx.Exec(`UPDATE recipe_versions v SET ingredients = (
+			SELECT COALESCE(jsonb_agg(CASE WHEN (x.e->>'ingredient_id')::int = $1
+					THEN x.e || jsonb_build_object('ingredient_id', $2::int,
+						'ingredient', jsonb_build_object('id', $2::int, 'name', $3::text))
+					ELSE x.e END ORDER BY x.ord), '[]'::jsonb)
+			FROM jsonb_array_elements(v.ingredients) WITH ORDINALITY AS x(e, ord)
+			WHERE NOT ((x.e->>'ingredient_id')::int = $1
+				AND v.ingredients @> jsonb_build_array(jsonb_build_object('ingredient_id', $2::int))))
+		WHERE v.ingredients @> jsonb_build_array(jsonb_build_object('ingredient_id', $1::int))`,
+		fromID, toID, result.Into.Name); err != nil {
+		return nil, fmt.Errorf("relink recipe versions: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE recipes SET updated_at = NOW() WHERE id = ANY($1)`, result.RecipeIDs); err != nil {
+		return nil, fmt.Errorf("touch merged recipes: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM ingredients WHERE id = $1`, fromID); err != nil {
+		return nil, fmt.Errorf("delete ingredient: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// lockIngredient reads ingredient id into ing, locking its row until tx ends.
+func lockIngredient(tx *sql.Tx, id int, ing *models.Ingredient) error {
+	err := tx.QueryRow(`SELECT id, name FROM ingredients WHERE id = $1 FOR UPDATE`, id).Scan(&ing.ID, &ing.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrIngredientNotFound
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package repository_test
+
+import (
+	"testing"
+
+	"cooking-app/internal/db/dbtest"
+	"cooking-app/internal/models"
+	"cooking-app/internal/repository"
+)
+
+func TestMergeIngredientsKeepsHistoryRevertible(t *testing.T) {
+	conn := dbtest.Open(t)
+	recipes := repository.NewRecipeRepository(conn)
+	ingredients := repository.NewIngredientRepository(conn)
+	owner := createUser(t, conn, "chef")
+
+	rec, err := recipes.Create(&models.CreateRecipeRequest{
+		Name:        "Fried rice",
+		Ingredients: []models.RecipeIngredient{{Name: "Scallion", Quantity: "2"}},
+	}, owner.ID, false)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := recipes.Update(rec.ID, &models.UpdateRecipeRequest{
+		Name:        "Fried rice",
+		Ingredients: []models.RecipeIngredient{{IngredientID: rec.Ingredients[0].IngredientID, Quantity: "3"}},
+	}, owner.ID); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	into, err := ingredients.CreateIngredient("Green onion")
+	if err != nil {
+		t.Fatalf("CreateIngredient: %v", err)
+	}
+	if _, err := ingredients.MergeIngredients(rec.Ingredients[0].IngredientID, into.ID); err != nil {
+		t.Fatalf("MergeIngredients: %v", err)
+	}
+
+	merged, err := recipes.GetByID(rec.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if !merged.UpdatedAt.After(rec.UpdatedAt) {
+		t.Errorf("updated_at = %v, want after %v", merged.UpdatedAt, rec.UpdatedAt)
+	}
+
+	reverted, err := recipes.Revert(rec.ID, 1, owner.ID)
+	if err != nil {
+		t.Fatalf("Revert to version 1: %v", err)
+	}
+	if len(reverted.Ingredients) != 1 || reverted.Ingredients[0].IngredientID != into.ID {
+		t.Errorf("reverted ingredients = %+v, want only ingredient %d", reverted.Ingredients, into.ID)
+	}
+}
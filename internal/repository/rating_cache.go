@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"cooking-app/internal/models"
+)
+
+// recipe_rating_cache holds each recipe's rating aggregates so GetRatingStats doesn't
+// scan ratings on every request. Rows are rewritten after every rating change and
+// rebuilt periodically by StartRatingCacheRefresher, which also repairs rows left
+// stale by concurrent writes. A missing row means "compute live".
+
+const refreshRatingCacheSQL = `
+	INSERT INTO recipe_rating_cache (recipe_id, average_rating, total_ratings, cooked_count, breakdown, refreshed_at)
+	SELECT $1, COALESCE(AVG(rating), 0)::float8, COUNT(*), COUNT(*) FILTER (WHERE cooked),
+		COALESCE((
+			SELECT jsonb_object_agg(rating, n)
+			FROM (SELECT rating, COUNT(*) AS n FROM ratings WHERE recipe_id = $1 GROUP BY rating) b
+		), '{}'::jsonb),
+		NOW()
+	FROM ratings
+	WHERE recipe_id = $1
+	ON CONFLICT (recipe_id) DO UPDATE SET
+		average_rating = EXCLUDED.average_rating,
+		total_ratings = EXCLUDED.total_ratings,
+		cooked_count = EXCLUDED.cooked_count,
+		breakdown = EXCLUDED.breakdown,
+		refreshed_at = EXCLUDED.refreshed_at`
+
+// invalidateUserRatingCacheSQL drops the cached stats of every recipe user $1 has
+// rated. Run it in the same transaction that deletes the user, since their ratings
+// are removed by ON DELETE CASCADE without passing through RatingRepository.
+const invalidateUserRatingCacheSQL = `
+	DELETE FROM recipe_rating_cache
+	WHERE recipe_id IN (SELECT recipe_id FROM ratings WHERE user_id = $1)`
+
+// refreshRatingCache recomputes one recipe's cached stats. Callers treat failure as
+// non-fatal: the periodic refresh repairs the row.
+func (r *RatingRepository) refreshRatingCache(recipeID int) error {
+	_, err := r.db.Exec(refreshRatingCacheSQL, recipeID)
+	return err
+}
+
+// cachedRatingStats reads a recipe's stats from the cache; sql.ErrNoRows means there is no row.
+func (r *RatingRepository) cachedRatingStats(recipeID int) (*models.RatingStats, error) {
+	stats := &models.RatingStats{RecipeID: recipeID}
+	var breakdown []byte
+	err := r.db.QueryRow(`
+		SELECT average_rating, total_ratings, cooked_count, breakdown
+		FROM recipe_rating_cache
+		WHERE recipe_id = $1`, recipeID).
+		Scan(&stats.AverageRating, &stats.TotalRatings, &stats.CookedCount, &breakdown)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(breakdown, &stats.RatingBreakdown); err != nil {
+		return nil, err
+	}
+	if stats.RatingBreakdown == nil {
+		stats.RatingBreakdown = make(map[int]int)
+	}
+	return stats, nil
+}
+
+// RefreshAllRatingStats rebuilds recipe_rating_cache from ratings in one transaction.
+func (r *RatingRepository) RefreshAllRatingStats() error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM recipe_rating_cache`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO recipe_rating_cache (recipe_id, average_rating, total_ratings, cooked_count, breakdown, refreshed_at)
+		SELECT s.recipe_id, s.average_rating, s.total_ratings, s.cooked_count, b.breakdown, NOW()
+		FROM (
+			SELECT recipe_id, AVG(rating)::float8 AS average_rating, COUNT(*) AS total_ratings,
+				COUNT(*) FILTER (WHERE cooked) AS cooked_count
+			FROM ratings
+			GROUP BY recipe_id
+		) s
+		JOIN (
+			SELECT recipe_id, jsonb_object_agg(rating, n) AS breakdown
+			FROM (SELECT recipe_id, rating, COUNT(*) AS n FROM ratings GROUP BY recipe_id, rating) x
+			GROUP BY recipe_id
+		) b ON b.recipe_id = s.recipe_id`); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// StartRatingCacheRefresher rebuilds the rating cache now and then every interval
+// in a background goroutine. An interval <= 0 disables it.
+func (r *RatingRepository) StartRatingCacheRefresher(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := r.RefreshAllRatingStats(); err != nil {
+				log.Printf("Warning: rating cache refresh failed: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
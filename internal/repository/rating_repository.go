@@ -3,26 +3,54 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"math"
 	"time"
 
 	"cooking-app/internal/models"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 var (
 	ErrRatingNotFound   = errors.New("rating not found")
 	ErrCommentNotFound  = errors.New("comment not found")
 	ErrCommentForbidden = errors.New("comment can only be modified by its author")
+	ErrInvalidParent    = errors.New("parent comment does not exist on this recipe")
+	ErrAlreadyReported  = errors.New("comment already reported by this user")
 )
 
 type RatingRepository struct {
-	db *sql.DB
+	db        *sql.DB
+	moderator CommentModerator
+	broker    *CommentBroker
 }
 
 func NewRatingRepository(db *sql.DB) *RatingRepository {
 	return &RatingRepository{db: db}
 }
 
-func (r *RatingRepository) CreateOrUpdateRating(recipeID, userID, rating int) (*models.Rating, error) {
+// SetModerator installs a CommentModerator that new and edited comments must pass.
+// With no moderator every comment is accepted.
+func (r *RatingRepository) SetModerator(m CommentModerator) {
+	r.moderator = m
+}
+
+// SetCommentBroker makes CreateComment publish each new comment to b.
+func (r *RatingRepository) SetCommentBroker(b *CommentBroker) {
+	r.broker = b
+}
+
+// moderate runs the configured moderator, if any, over comment content.
+func (r *RatingRepository) moderate(content string) error {
+	if r.moderator == nil {
+		return nil
+	}
+	return r.moderator.Check(content)
+}
+
+// CreateOrUpdateRating records userID's rating of a recipe; cooked marks that they
+// made it. Re-rating overwrites both values.
+func (r *RatingRepository) CreateOrUpdateRating(recipeID, userID, rating int, cooked bool) (*models.Rating, error) {
 	if rating < 1 || rating > 5 {
 		return nil, errors.New("rating must be between 1 and 5")
 	}
@@ -30,31 +58,26 @@ func (r *RatingRepository) CreateOrUpdateRating(recipeID, userID, rating int) (*
 	var id int
 	var createdAt, updatedAt time.Time
 
+	// A single upsert is atomic, so concurrent ratings by the same user can't both
+	// try to insert and trip the UNIQUE(recipe_id, user_id) constraint.
 	err := r.db.QueryRow(`
-		UPDATE ratings 
-		SET rating = $1, updated_at = NOW() 
-		WHERE recipe_id = $2 AND user_id = $3 
+		INSERT INTO ratings (recipe_id, user_id, rating, cooked, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (recipe_id, user_id) DO UPDATE
+		SET rating = EXCLUDED.rating, cooked = EXCLUDED.cooked, updated_at = NOW()
 		RETURNING id, created_at, updated_at`,
-		rating, recipeID, userID).Scan(&id, &createdAt, &updatedAt)
-
-	if err == sql.ErrNoRows {
-		err = r.db.QueryRow(`
-			INSERT INTO ratings (recipe_id, user_id, rating, created_at, updated_at)
-			VALUES ($1, $2, $3, NOW(), NOW())
-			RETURNING id, created_at, updated_at`,
-			recipeID, userID, rating).Scan(&id, &createdAt, &updatedAt)
-		if err != nil {
-			return nil, err
-		}
-	} else if err != nil {
+		recipeID, userID, rating, cooked).Scan(&id, &createdAt, &updatedAt)
+	if err != nil {
 		return nil, err
 	}
+	r.refreshRatingCache(recipeID)
 
 	return &models.Rating{
 		ID:        id,
 		RecipeID:  recipeID,
 		UserID:    userID,
 		Rating:    rating,
+		Cooked:    cooked,
 		CreatedAt: createdAt,
 		UpdatedAt: updatedAt,
 	}, nil
@@ -62,7 +85,7 @@ func (r *RatingRepository) CreateOrUpdateRating(recipeID, userID, rating int) (*
 
 func (r *RatingRepository) GetRatingsByRecipe(recipeID int) ([]*models.Rating, error) {
 	rows, err := r.db.Query(`
-		SELECT id, recipe_id, user_id, rating, created_at, updated_at
+		SELECT id, recipe_id, user_id, rating, cooked, created_at, updated_at
 		FROM ratings
 		WHERE recipe_id = $1
 		ORDER BY created_at DESC`, recipeID)
@@ -75,7 +98,7 @@ func (r *RatingRepository) GetRatingsByRecipe(recipeID int) ([]*models.Rating, e
 	for rows.Next() {
 		var rating models.Rating
 		if err := rows.Scan(&rating.ID, &rating.RecipeID, &rating.UserID,
-			&rating.Rating, &rating.CreatedAt, &rating.UpdatedAt); err != nil {
+			&rating.Rating, &rating.Cooked, &rating.CreatedAt, &rating.UpdatedAt); err != nil {
 			continue
 		}
 		ratings = append(ratings, &rating)
@@ -84,14 +107,51 @@ func (r *RatingRepository) GetRatingsByRecipe(recipeID int) ([]*models.Rating, e
 	return ratings, nil
 }
 
+// GetRatingsByUser lists userID's ratings of non-deleted recipes, newest first.
+func (r *RatingRepository) GetRatingsByUser(userID, limit, offset int) ([]*models.UserRating, error) {
+	rows, err := r.db.Query(`
+		SELECT ra.id, ra.recipe_id, ra.user_id, ra.rating, ra.cooked, ra.created_at, ra.updated_at, re.name
+		FROM ratings ra
+		JOIN recipes re ON re.id = ra.recipe_id
+		WHERE ra.user_id = $1 AND re.deleted_at IS NULL
+		ORDER BY ra.updated_at DESC, ra.id DESC
+		LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := []*models.UserRating{}
+	for rows.Next() {
+		var ur models.UserRating
+		if err := rows.Scan(&ur.ID, &ur.RecipeID, &ur.UserID, &ur.Rating.Rating, &ur.Cooked,
+			&ur.CreatedAt, &ur.UpdatedAt, &ur.RecipeName); err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, &ur)
+	}
+	return ratings, rows.Err()
+}
+
+// CountRatingsByUser counts the ratings GetRatingsByUser pages through.
+func (r *RatingRepository) CountRatingsByUser(userID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM ratings ra
+		JOIN recipes re ON re.id = ra.recipe_id
+		WHERE ra.user_id = $1 AND re.deleted_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
 func (r *RatingRepository) GetUserRatingForRecipe(recipeID, userID int) (*models.Rating, error) {
 	var rating models.Rating
 	err := r.db.QueryRow(`
-		SELECT id, recipe_id, user_id, rating, created_at, updated_at
+		SELECT id, recipe_id, user_id, rating, cooked, created_at, updated_at
 		FROM ratings
 		WHERE recipe_id = $1 AND user_id = $2`, recipeID, userID).
 		Scan(&rating.ID, &rating.RecipeID, &rating.UserID,
-			&rating.Rating, &rating.CreatedAt, &rating.UpdatedAt)
+			&rating.Rating, &rating.Cooked, &rating.CreatedAt, &rating.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrRatingNotFound
@@ -103,17 +163,58 @@ func (r *RatingRepository) GetUserRatingForRecipe(recipeID, userID int) (*models
 	return &rating, nil
 }
 
+// DeleteRating removes userID's rating of a recipe, or returns ErrRatingNotFound.
+func (r *RatingRepository) DeleteRating(recipeID, userID int) error {
+	res, err := r.db.Exec(`DELETE FROM ratings WHERE recipe_id = $1 AND user_id = $2`, recipeID, userID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return ErrRatingNotFound
+	}
+	r.refreshRatingCache(recipeID)
+	return nil
+}
+
+// GetRatingStats returns a recipe's rating aggregates from recipe_rating_cache,
+// computing them live (and caching them) when the recipe has no cache row.
 func (r *RatingRepository) GetRatingStats(recipeID int) (*models.RatingStats, error) {
+	stats, err := r.cachedRatingStats(recipeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		stats, err = r.liveRatingStats(recipeID)
+		if err == nil {
+			r.refreshRatingCache(recipeID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stats.RatingPercentages = make(map[int]float64, 5)
+	for star := 1; star <= 5; star++ {
+		pct := 0.0
+		if stats.TotalRatings > 0 {
+			pct = math.Round(float64(stats.RatingBreakdown[star])*1000/float64(stats.TotalRatings)) / 10
+		}
+		stats.RatingPercentages[star] = pct
+	}
+
+	return stats, nil
+}
+
+// liveRatingStats aggregates a recipe's ratings directly from the ratings table.
+func (r *RatingRepository) liveRatingStats(recipeID int) (*models.RatingStats, error) {
 	stats := &models.RatingStats{
 		RecipeID:        recipeID,
 		RatingBreakdown: make(map[int]int),
 	}
 
 	err := r.db.QueryRow(`
-		SELECT COALESCE(AVG(rating), 0), COUNT(*)
+		SELECT COALESCE(AVG(rating), 0), COUNT(*), COUNT(*) FILTER (WHERE cooked)
 		FROM ratings
 		WHERE recipe_id = $1`, recipeID).
-		Scan(&stats.AverageRating, &stats.TotalRatings)
+		Scan(&stats.AverageRating, &stats.TotalRatings, &stats.CookedCount)
 	if err != nil {
 		return nil, err
 	}
@@ -138,44 +239,75 @@ func (r *RatingRepository) GetRatingStats(recipeID int) (*models.RatingStats, er
 	return stats, nil
 }
 
-func (r *RatingRepository) CreateComment(recipeID, userID int, content string) (*models.Comment, error) {
+// CreateComment adds a comment to a recipe. parentID, when set, makes it a reply and
+// must reference a comment on the same recipe (ErrInvalidParent otherwise).
+func (r *RatingRepository) CreateComment(recipeID, userID int, content string, parentID *int) (*models.Comment, error) {
 	if content == "" {
 		return nil, errors.New("comment content cannot be empty")
 	}
 
+	if parentID != nil {
+		parent, err := r.GetCommentByID(*parentID)
+		if err != nil {
+			if errors.Is(err, ErrCommentNotFound) {
+				return nil, ErrInvalidParent
+			}
+			return nil, err
+		}
+		if parent.RecipeID != recipeID {
+			return nil, ErrInvalidParent
+		}
+	}
+
+	if err := r.moderate(content); err != nil {
+		return nil, err
+	}
+
 	var id int
 	var createdAt, updatedAt time.Time
 	var username string
 
 	err := r.db.QueryRow(`
-		INSERT INTO comments (recipe_id, user_id, content, created_at, updated_at)
-		VALUES ($1, $2, $3, NOW(), NOW())
+		INSERT INTO comments (recipe_id, user_id, parent_id, content, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
 		RETURNING id, created_at, updated_at`,
-		recipeID, userID, content).Scan(&id, &createdAt, &updatedAt)
+		recipeID, userID, parentID, content).Scan(&id, &createdAt, &updatedAt)
 	if err != nil {
 		return nil, err
 	}
 
 	r.db.QueryRow("SELECT username FROM users WHERE id = $1", userID).Scan(&username)
 
-	return &models.Comment{
+	comment := &models.Comment{
 		ID:        id,
 		RecipeID:  recipeID,
 		UserID:    userID,
+		ParentID:  parentID,
 		Username:  username,
 		Content:   content,
 		CreatedAt: createdAt,
 		UpdatedAt: updatedAt,
-	}, nil
+	}
+	if r.broker != nil {
+		r.broker.Publish(comment)
+	}
+	return comment, nil
 }
 
-func (r *RatingRepository) GetCommentsByRecipe(recipeID int) ([]*models.Comment, error) {
+// GetCommentsByRecipe returns one page of a recipe's comments. sort is "newest"
+// (default) or "oldest".
+func (r *RatingRepository) GetCommentsByRecipe(recipeID, limit, offset int, sort string) ([]*models.Comment, error) {
+	order := "c.created_at DESC, c.id DESC"
+	if sort == "oldest" {
+		order = "c.created_at ASC, c.id ASC"
+	}
 	rows, err := r.db.Query(`
-		SELECT c.id, c.recipe_id, c.user_id, u.username, c.content, c.created_at, c.updated_at
+		SELECT c.id, c.recipe_id, c.user_id, c.parent_id, u.username, c.content, c.created_at, c.updated_at
 		FROM comments c
 		JOIN users u ON u.id = c.user_id
 		WHERE c.recipe_id = $1
-		ORDER BY c.created_at DESC`, recipeID)
+		ORDER BY `+order+`
+		LIMIT $2 OFFSET $3`, recipeID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -184,26 +316,39 @@ func (r *RatingRepository) GetCommentsByRecipe(recipeID int) ([]*models.Comment,
 	var comments []*models.Comment
 	for rows.Next() {
 		var comment models.Comment
-		if err := rows.Scan(&comment.ID, &comment.RecipeID, &comment.UserID,
+		var parentID sql.NullInt64
+		if err := rows.Scan(&comment.ID, &comment.RecipeID, &comment.UserID, &parentID,
 			&comment.Username, &comment.Content, &comment.CreatedAt, &comment.UpdatedAt); err != nil {
 			continue
 		}
+		if parentID.Valid {
+			pid := int(parentID.Int64)
+			comment.ParentID = &pid
+		}
 		comments = append(comments, &comment)
 	}
 
 	return comments, nil
 }
 
+// CountCommentsByRecipe returns the total number of comments on a recipe.
+func (r *RatingRepository) CountCommentsByRecipe(recipeID int) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM comments WHERE recipe_id = $1", recipeID).Scan(&count)
+	return count, err
+}
+
 func (r *RatingRepository) GetCommentByID(id int) (*models.Comment, error) {
 	var comment models.Comment
 	var username string
+	var parentID sql.NullInt64
 
 	err := r.db.QueryRow(`
-		SELECT c.id, c.recipe_id, c.user_id, u.username, c.content, c.created_at, c.updated_at
+		SELECT c.id, c.recipe_id, c.user_id, c.parent_id, u.username, c.content, c.created_at, c.updated_at
 		FROM comments c
 		JOIN users u ON u.id = c.user_id
 		WHERE c.id = $1`, id).
-		Scan(&comment.ID, &comment.RecipeID, &comment.UserID,
+		Scan(&comment.ID, &comment.RecipeID, &comment.UserID, &parentID,
 			&username, &comment.Content, &comment.CreatedAt, &comment.UpdatedAt)
 
 	if err == sql.ErrNoRows {
@@ -214,6 +359,10 @@ func (r *RatingRepository) GetCommentByID(id int) (*models.Comment, error) {
 	}
 
 	comment.Username = username
+	if parentID.Valid {
+		pid := int(parentID.Int64)
+		comment.ParentID = &pid
+	}
 	return &comment, nil
 }
 
@@ -231,6 +380,10 @@ func (r *RatingRepository) UpdateComment(id, userID int, content string) (*model
 		return nil, ErrCommentForbidden
 	}
 
+	if err := r.moderate(content); err != nil {
+		return nil, err
+	}
+
 	_, err = r.db.Exec(`
 		UPDATE comments 
 		SET content = $1, updated_at = NOW()
@@ -264,3 +417,62 @@ func (r *RatingRepository) DeleteComment(id, userID int) error {
 
 	return nil
 }
+
+// ReportComment flags a comment for moderation. Each user may report a comment once;
+// a second report returns ErrAlreadyReported.
+func (r *RatingRepository) ReportComment(commentID, userID int, reason string) (*models.CommentReport, error) {
+	if _, err := r.GetCommentByID(commentID); err != nil {
+		return nil, err
+	}
+
+	report := &models.CommentReport{CommentID: commentID, ReporterUserID: userID, Reason: reason}
+	err := r.db.QueryRow(`
+		INSERT INTO comment_reports (comment_id, reporter_user_id, reason, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (comment_id, reporter_user_id) DO NOTHING
+		RETURNING id, created_at`,
+		commentID, userID, reason).Scan(&report.ID, &report.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAlreadyReported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetReportedComments returns flagged comments, most-reported first.
+func (r *RatingRepository) GetReportedComments() ([]*models.ReportedComment, error) {
+	rows, err := r.db.Query(`
+		SELECT c.id, c.recipe_id, c.user_id, c.parent_id, u.username, c.content, c.created_at, c.updated_at,
+			COUNT(cr.id), ARRAY_AGG(cr.reason ORDER BY cr.created_at), MAX(cr.created_at)
+		FROM comment_reports cr
+		JOIN comments c ON c.id = cr.comment_id
+		JOIN users u ON u.id = c.user_id
+		GROUP BY c.id, u.username
+		ORDER BY COUNT(cr.id) DESC, MAX(cr.created_at) DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// database/sql can't scan a Postgres array directly; pgtype adapts it.
+	typeMap := pgtype.NewMap()
+	var reported []*models.ReportedComment
+	for rows.Next() {
+		var comment models.Comment
+		var parentID sql.NullInt64
+		item := &models.ReportedComment{Comment: &comment}
+		if err := rows.Scan(&comment.ID, &comment.RecipeID, &comment.UserID, &parentID,
+			&comment.Username, &comment.Content, &comment.CreatedAt, &comment.UpdatedAt,
+			&item.ReportCount, typeMap.SQLScanner(&item.Reasons), &item.LastReportedAt); err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			pid := int(parentID.Int64)
+			comment.ParentID = &pid
+		}
+		reported = append(reported, item)
+	}
+	return reported, rows.Err()
+}
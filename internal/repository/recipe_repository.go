@@ -2,7 +2,10 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math/rand/v2"
 	"strconv"
 	"strings"
 	"time"
@@ -13,11 +16,52 @@ import (
 var (
 	ErrRecipeNotFound  = errors.New("recipe not found")
 	ErrRecipeForbidden = errors.New("recipe can only be changed or deleted by its creator")
+	ErrVersionNotFound = errors.New("recipe version not found")
 )
 
+// InvalidIngredientsError is returned by Create and Update when the request links
+// ingredient IDs that don't exist.
+type InvalidIngredientsError struct {
+	IDs []int
+}
+
+// DuplicateRecipeError is returned by Create and CreateBatch when the user already
+// has a recipe with the same name, ignoring case.
+type DuplicateRecipeError struct {
+	ExistingID int
+}
+
+func (e *DuplicateRecipeError) Error() string {
+	return fmt.Sprintf("you already have a recipe with this name (id %d)", e.ExistingID)
+}
+
+func (e *InvalidIngredientsError) Error() string {
+	return "unknown ingredient IDs: " + joinIDs(e.IDs)
+}
+
+// DuplicateIngredientsError is returned by Create, Update and CreateBatch when the
+// request lists the same ingredient more than once, by ID or by a name resolving to it.
+type DuplicateIngredientsError struct {
+	IDs []int
+}
+
+func (e *DuplicateIngredientsError) Error() string {
+	return "ingredients listed more than once: " + joinIDs(e.IDs)
+}
+
+func joinIDs(ids []int) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(id)
+	}
+	return strings.Join(s, ", ")
+}
+
 // RecipeRepository stores recipes and ingredients in PostgreSQL.
 type RecipeRepository struct {
 	db *sql.DB
+	// lockLegacy makes recipes without a creator (user_id NULL) read-only.
+	lockLegacy bool
 }
 
 // NewRecipeRepository creates a new repository backed by PostgreSQL.
@@ -25,28 +69,94 @@ func NewRecipeRepository(db *sql.DB) *RecipeRepository {
 	return &RecipeRepository{db: db}
 }
 
-// scanRecipe scans a recipe row and loads ingredients in a second query.
-func (r *RecipeRepository) scanRecipe(row *sql.Row) (*models.Recipe, error) {
+// SetLegacyRecipesLocked controls whether legacy recipes with no creator can be
+// changed by any authenticated user (false, the default) or by nobody (true).
+func (r *RecipeRepository) SetLegacyRecipesLocked(locked bool) {
+	r.lockLegacy = locked
+}
+
+// checkOwner returns ErrRecipeForbidden unless userID may modify a recipe owned by
+// ownerID. Orphaned recipes, whose creator deleted their account, are read-only.
+func (r *RecipeRepository) checkOwner(ownerID *int, orphaned bool, userID int) error {
+	if orphaned {
+		return ErrRecipeForbidden
+	}
+	if ownerID == nil {
+		if r.lockLegacy {
+			return ErrRecipeForbidden
+		}
+		return nil
+	}
+	if *ownerID != userID {
+		return ErrRecipeForbidden
+	}
+	return nil
+}
+
+// recipeColumns is the column list scanned by scanRecipeFields.
+const recipeColumns = `id, name, description, instructions, prep_time_min, cook_time_min, user_id, created_at,
+	calories, protein_g, carbs_g, fat_g, servings, difficulty, updated_at, view_count, steps, orphaned`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRecipeFields scans the recipeColumns of one row (without ingredients). Any extra
+// destinations are scanned from the columns selected after recipeColumns.
+func scanRecipeFields(row rowScanner, extra ...interface{}) (*models.Recipe, error) {
 	var rec models.Recipe
 	var desc, instructions sql.NullString
-	var userID sql.NullInt64
-	err := row.Scan(&rec.ID, &rec.Name, &desc, &instructions, &rec.PrepTimeMin, &rec.CookTimeMin, &userID, &rec.CreatedAt)
+	var userID, calories sql.NullInt64
+	var protein, carbs, fat sql.NullFloat64
+	var steps []byte
+	dest := []interface{}{&rec.ID, &rec.Name, &desc, &instructions, &rec.PrepTimeMin, &rec.CookTimeMin, &userID, &rec.CreatedAt,
+		&calories, &protein, &carbs, &fat, &rec.Servings, &rec.Difficulty, &rec.UpdatedAt, &rec.ViewCount, &steps, &rec.Orphaned}
+	err := row.Scan(append(dest, extra...)...)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrRecipeNotFound
-		}
 		return nil, err
 	}
+	if err := json.Unmarshal(steps, &rec.Steps); err != nil {
+		return nil, fmt.Errorf("decode recipe steps: %w", err)
+	}
+	if rec.Steps == nil {
+		rec.Steps = []string{}
+	}
 	rec.Description = desc.String
 	rec.Instructions = instructions.String
 	if userID.Valid {
 		uid := int(userID.Int64)
 		rec.UserID = &uid
 	}
-	rec.Ingredients, _ = r.loadIngredients(rec.ID)
+	if calories.Valid {
+		c := int(calories.Int64)
+		rec.Calories = &c
+	}
+	if protein.Valid {
+		rec.ProteinG = &protein.Float64
+	}
+	if carbs.Valid {
+		rec.CarbsG = &carbs.Float64
+	}
+	if fat.Valid {
+		rec.FatG = &fat.Float64
+	}
 	return &rec, nil
 }
 
+// scanRecipe scans a recipe row and loads ingredients in a second query.
+func (r *RecipeRepository) scanRecipe(row *sql.Row) (*models.Recipe, error) {
+	rec, err := scanRecipeFields(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecipeNotFound
+		}
+		return nil, err
+	}
+	rec.Ingredients, _ = r.loadIngredients(rec.ID)
+	return rec, nil
+}
+
 func (r *RecipeRepository) loadIngredients(recipeID int) ([]models.RecipeIngredient, error) {
 	rows, err := r.db.Query(`SELECT ri.recipe_id, ri.ingredient_id, ri.quantity, i.name
 		FROM recipe_ingredients ri JOIN ingredients i ON i.id = ri.ingredient_id
@@ -64,6 +174,7 @@ func (r *RecipeRepository) loadIngredients(recipeID int) ([]models.RecipeIngredi
 			continue
 		}
 		ri.Ingredient = models.Ingredient{ID: ri.IngredientID, Name: name}
+		ri.Amount, ri.Unit, _ = models.ParseQuantity(ri.Quantity)
 		list = append(list, ri)
 	}
 	return list, nil
@@ -71,94 +182,679 @@ func (r *RecipeRepository) loadIngredients(recipeID int) ([]models.RecipeIngredi
 
 // GetByID returns a recipe by ID with ingredients.
 func (r *RecipeRepository) GetByID(id int) (*models.Recipe, error) {
-	row := r.db.QueryRow(`SELECT id, name, description, instructions, prep_time_min, cook_time_min, user_id, created_at
-		FROM recipes WHERE id = $1`, id)
+	row := r.db.QueryRow(`SELECT `+recipeColumns+`
+		FROM recipes WHERE id = $1 AND deleted_at IS NULL`, id)
 	return r.scanRecipe(row)
 }
 
+// GetByIDs returns the non-deleted recipes among ids, with ingredients, in the order
+// the IDs are given, in one query. Unknown or deleted IDs are left out.
+func (r *RecipeRepository) GetByIDs(ids []int) ([]*models.Recipe, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var list []*models.Recipe
+	err := r.eachRecipe("deleted_at IS NULL AND id = ANY($1)", "array_position($1, id)", 0, 0, func(rec *models.Recipe) error {
+		list = append(list, rec)
+		return nil
+	}, ids)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetRandom returns one randomly chosen non-deleted recipe, optionally limited to a
+// difficulty ("" for any) and a maximum prep + cook time (< 0 for any). It counts the
+// matching rows and reads the one at a random offset, so nothing but the chosen
+// recipe is loaded. Returns ErrRecipeNotFound when no recipe matches.
+func (r *RecipeRepository) GetRandom(difficulty string, maxTotalTime int) (*models.Recipe, error) {
+	where, args := RecipeListFilter{Difficulty: difficulty, MinTotalTime: -1, MaxTotalTime: maxTotalTime}.where()
+
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM recipes WHERE `+where, args...).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrRecipeNotFound
+	}
+	args = append(args, rand.IntN(count))
+	return r.scanRecipe(r.db.QueryRow(`SELECT `+recipeColumns+`
+		FROM recipes WHERE `+where+` ORDER BY id OFFSET $`+strconv.Itoa(len(args))+` LIMIT 1`, args...))
+}
+
+// Exists reports whether a recipe with id exists and isn't deleted.
+func (r *RecipeRepository) Exists(id int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM recipes WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists)
+	return exists, err
+}
+
+// recipeSortColumns whitelists the ORDER BY expressions exposed via ?sort=.
+// User input is only ever used as a key into this map, never interpolated.
+var recipeSortColumns = map[string]string{
+	"prep_time":  "prep_time_min",
+	"cook_time":  "cook_time_min",
+	"total_time": "(prep_time_min + cook_time_min)",
+	"name":       "LOWER(name)",
+	"created_at": "created_at",
+}
+
+// recipeOrderBy builds a safe ORDER BY clause. Unknown sort keys fall back to "id ASC".
+func recipeOrderBy(sortBy, order string) string {
+	col, ok := recipeSortColumns[strings.ToLower(strings.TrimSpace(sortBy))]
+	if !ok {
+		return "id ASC"
+	}
+	dir := "ASC"
+	if strings.EqualFold(strings.TrimSpace(order), "desc") {
+		dir = "DESC"
+	}
+	// Secondary key on id keeps ties in a stable order.
+	return col + " " + dir + ", id ASC"
+}
+
 // GetAll returns all recipes with ingredients.
 func (r *RecipeRepository) GetAll() []*models.Recipe {
-	rows, err := r.db.Query(`SELECT id, name, description, instructions, prep_time_min, cook_time_min, user_id, created_at FROM recipes ORDER BY id`)
+	return r.GetAllSorted("", "")
+}
+
+// GetAllSorted returns all recipes ordered by sortBy (prep_time, cook_time, total_time,
+// name, created_at) in the given order (asc or desc). Unknown values order by id.
+func (r *RecipeRepository) GetAllSorted(sortBy, order string) []*models.Recipe {
+	return r.queryRecipes("deleted_at IS NULL", recipeOrderBy(sortBy, order), 0, 0)
+}
+
+// GetPaged returns one page of recipes in GetAll's order (by id), loading only that
+// page from the database. limit <= 0 returns all.
+func (r *RecipeRepository) GetPaged(limit, offset int) []*models.Recipe {
+	return r.queryRecipes("deleted_at IS NULL", recipeOrderBy("", ""), limit, offset)
+}
+
+// RecipeListFilter narrows ListFiltered. An empty Difficulty and negative times match
+// any recipe; the times bound prep + cook time in minutes, inclusive.
+type RecipeListFilter struct {
+	Difficulty   string
+	MinTotalTime int
+	MaxTotalTime int
+}
+
+// where returns the SQL condition selecting non-deleted recipes that match f, with
+// its arguments numbered from $1.
+func (f RecipeListFilter) where() (string, []interface{}) {
+	where := "deleted_at IS NULL"
+	var args []interface{}
+	if f.Difficulty != "" {
+		args = append(args, f.Difficulty)
+		where += " AND difficulty = $" + strconv.Itoa(len(args))
+	}
+	if f.MinTotalTime >= 0 {
+		args = append(args, f.MinTotalTime)
+		where += " AND prep_time_min + cook_time_min >= $" + strconv.Itoa(len(args))
+	}
+	if f.MaxTotalTime >= 0 {
+		args = append(args, f.MaxTotalTime)
+		where += " AND prep_time_min + cook_time_min <= $" + strconv.Itoa(len(args))
+	}
+	return where, args
+}
+
+// Matches reports whether rec passes f, for results that were not loaded through it.
+func (f RecipeListFilter) Matches(rec *models.Recipe) bool {
+	total := rec.PrepTimeMin + rec.CookTimeMin
+	return (f.Difficulty == "" || rec.Difficulty == f.Difficulty) &&
+		(f.MinTotalTime < 0 || total >= f.MinTotalTime) &&
+		(f.MaxTotalTime < 0 || total <= f.MaxTotalTime)
+}
+
+// ListFiltered returns one page of the recipes matching filter, ordered as in
+// GetAllSorted, together with the number of matching recipes. Filtering and paging
+// happen in the database. limit <= 0 returns all matches.
+func (r *RecipeRepository) ListFiltered(filter RecipeListFilter, sortBy, order string, limit, offset int) ([]*models.Recipe, int, error) {
+	where, args := filter.where()
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM recipes WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	list := []*models.Recipe{}
+	if total == 0 || offset >= total {
+		return list, total, nil
+	}
+	err := r.eachRecipe(where, recipeOrderBy(sortBy, order), limit, offset, func(rec *models.Recipe) error {
+		list = append(list, rec)
+		return nil
+	}, args...)
 	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// GetByUser returns recipes created by userID, newest first. limit <= 0 returns all.
+func (r *RecipeRepository) GetByUser(userID, limit, offset int) []*models.Recipe {
+	return r.queryRecipes("deleted_at IS NULL AND user_id = $1", "created_at DESC, id DESC", limit, offset, userID)
+}
+
+// CountByUser counts userID's non-deleted recipes.
+func (r *RecipeRepository) CountByUser(userID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM recipes WHERE deleted_at IS NULL AND user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+// StreamByUser calls fn for each of userID's recipes (with ingredients), oldest first,
+// without holding the whole set in memory. Iteration stops at the first error from fn,
+// which is returned.
+func (r *RecipeRepository) StreamByUser(userID int, fn func(*models.Recipe) error) error {
+	return r.eachRecipe("deleted_at IS NULL AND user_id = $1", "created_at ASC, id ASC", 0, 0, fn, userID)
+}
+
+// queryRecipes loads recipes matching where (a condition on the recipes table) together
+// with their ingredients in a single LEFT JOIN query, preserving orderBy. The recipe
+// order is captured with ROW_NUMBER() so orderBy may use unqualified recipe columns.
+// When limit > 0 only that page of recipes (after offset) is loaded.
+func (r *RecipeRepository) queryRecipes(where, orderBy string, limit, offset int, args ...interface{}) []*models.Recipe {
+	var list []*models.Recipe
+	r.eachRecipe(where, orderBy, limit, offset, func(rec *models.Recipe) error {
+		list = append(list, rec)
 		return nil
+	}, args...)
+	return list
+}
+
+// eachRecipe runs the queryRecipes query and passes each recipe to fn as soon as all
+// of its ingredient rows have been read.
+func (r *RecipeRepository) eachRecipe(where, orderBy string, limit, offset int, fn func(*models.Recipe) error, args ...interface{}) error {
+	page := ""
+	if limit > 0 {
+		page = " ORDER BY " + orderBy + " LIMIT " + strconv.Itoa(limit) + " OFFSET " + strconv.Itoa(offset)
+	}
+	rows, err := r.db.Query(`SELECT rec.*, ri.ingredient_id, ri.quantity, i.name
+		FROM (
+			SELECT `+recipeColumns+`, ROW_NUMBER() OVER (ORDER BY `+orderBy+`) AS rn
+			FROM recipes WHERE `+where+page+`
+		) rec
+		LEFT JOIN recipe_ingredients ri ON ri.recipe_id = rec.id
+		LEFT JOIN ingredients i ON i.id = ri.ingredient_id
+		ORDER BY rec.rn, ri.ingredient_id`, args...)
+	if err != nil {
+		return err
 	}
 	defer rows.Close()
 
-	var list []*models.Recipe
+	var current *models.Recipe
 	for rows.Next() {
-		var rec models.Recipe
-		var desc, instructions sql.NullString
-		var userID sql.NullInt64
-		if err := rows.Scan(&rec.ID, &rec.Name, &desc, &instructions, &rec.PrepTimeMin, &rec.CookTimeMin, &userID, &rec.CreatedAt); err != nil {
+		var rn int64
+		var ingID sql.NullInt64
+		var qty, ingName sql.NullString
+		rec, err := scanRecipeFields(rows, &rn, &ingID, &qty, &ingName)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.ID != rec.ID {
+			if current != nil {
+				if err := fn(current); err != nil {
+					return err
+				}
+			}
+			current = rec
+		}
+		if ingID.Valid {
+			id := int(ingID.Int64)
+			amount, unit, _ := models.ParseQuantity(qty.String)
+			current.Ingredients = append(current.Ingredients, models.RecipeIngredient{
+				RecipeID:     current.ID,
+				IngredientID: id,
+				Ingredient:   models.Ingredient{ID: id, Name: ingName.String},
+				Quantity:     qty.String,
+				Amount:       amount,
+				Unit:         unit,
+			})
+		}
+	}
+	if current != nil {
+		if err := fn(current); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// resolveIngredientID finds an ingredient by name (case-insensitive) inside tx,
+// creating it when missing.
+func resolveIngredientID(tx *sql.Tx, name string) (int, error) {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM ingredients WHERE LOWER(name) = LOWER($1) ORDER BY id LIMIT 1`, name).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	err = tx.QueryRow(`INSERT INTO ingredients (name) VALUES ($1) RETURNING id`, name).Scan(&id)
+	return id, err
+}
+
+// insertIngredients links ingredients to a recipe inside tx. Entries without an
+// ingredient ID are resolved by name, creating the ingredient if needed. All IDs
+// are checked first, in one query, so a bad ID fails the whole write with
+// *InvalidIngredientsError, and an ingredient listed twice with *DuplicateIngredientsError.
+func insertIngredients(tx *sql.Tx, recipeID int, ingredients []models.RecipeIngredient) error {
+	if len(ingredients) == 0 {
+		return nil
+	}
+	for i := range ingredients {
+		ri := &ingredients[i]
+		name := NormalizeIngredientName(ri.Name)
+		if ri.IngredientID != 0 || name == "" {
 			continue
 		}
-		rec.Description = desc.String
-		rec.Instructions = instructions.String
-		if userID.Valid {
-			uid := int(userID.Int64)
-			rec.UserID = &uid
+		id, err := resolveIngredientID(tx, name)
+		if err != nil {
+			return fmt.Errorf("resolve ingredient %q: %w", name, err)
 		}
-		rec.Ingredients, _ = r.loadIngredients(rec.ID)
-		list = append(list, &rec)
+		ri.IngredientID = id
 	}
-	return list
+
+	ids := make([]int, len(ingredients))
+	seen := make(map[int]bool, len(ingredients))
+	var repeated []int
+	for i, ri := range ingredients {
+		ids[i] = ri.IngredientID
+		if seen[ri.IngredientID] {
+			repeated = append(repeated, ri.IngredientID)
+		}
+		seen[ri.IngredientID] = true
+	}
+	if len(repeated) > 0 {
+		return &DuplicateIngredientsError{IDs: repeated}
+	}
+	rows, err := tx.Query(`SELECT id FROM ingredients WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return err
+	}
+	known := make(map[int]bool, len(ids))
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		known[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var invalid []int
+	for _, id := range ids {
+		if !known[id] {
+			invalid = append(invalid, id)
+		}
+	}
+	if len(invalid) > 0 {
+		return &InvalidIngredientsError{IDs: invalid}
+	}
+
+	for _, ri := range ingredients {
+		if _, err := tx.Exec(`INSERT INTO recipe_ingredients (recipe_id, ingredient_id, quantity) VALUES ($1, $2, $3)`,
+			recipeID, ri.IngredientID, ri.Quantity); err != nil {
+			return fmt.Errorf("insert recipe ingredient %d: %w", ri.IngredientID, err)
+		}
+	}
+	return nil
 }
 
-// Create inserts a new recipe and its ingredients. userID is the creator (required).
-func (r *RecipeRepository) Create(req *models.CreateRecipeRequest, userID int) *models.Recipe {
+// insertRecipe inserts one recipe and its ingredients inside tx and returns its ID.
+// Unless allowDuplicate is set it first returns *DuplicateRecipeError if userID
+// already has a non-deleted recipe with the same name, ignoring case.
+func insertRecipe(tx *sql.Tx, req *models.CreateRecipeRequest, userID int, allowDuplicate bool) (int, error) {
+	if !allowDuplicate {
+		var existing int
+		err := tx.QueryRow(`SELECT id FROM recipes
+			WHERE user_id = $1 AND LOWER(name) = LOWER($2) AND deleted_at IS NULL
+			ORDER BY id LIMIT 1`, userID, req.Name).Scan(&existing)
+		if err == nil {
+			return 0, &DuplicateRecipeError{ExistingID: existing}
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+	}
+
 	var id int
 	var createdAt time.Time
-	err := r.db.QueryRow(`INSERT INTO recipes (name, description, instructions, prep_time_min, cook_time_min, user_id)
-		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
-		req.Name, req.Description, req.Instructions, req.PrepTimeMin, req.CookTimeMin, userID).Scan(&id, &createdAt)
+	servings := 1
+	if req.Servings != nil {
+		servings = *req.Servings
+	}
+	difficulty := models.DifficultyMedium
+	if req.Difficulty != "" {
+		difficulty = req.Difficulty
+	}
+	steps := models.NormalizeSteps(req.Steps)
+	instructions := req.Instructions
+	if instructions == "" {
+		instructions = models.StepsText(steps)
+	}
+	stepsJSON, err := json.Marshal(steps)
 	if err != nil {
-		return nil
+		return 0, err
+	}
+	err = tx.QueryRow(`INSERT INTO recipes (name, description, instructions, prep_time_min, cook_time_min, user_id,
+			calories, protein_g, carbs_g, fat_g, servings, difficulty, steps)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, created_at`,
+		req.Name, req.Description, instructions, req.PrepTimeMin, req.CookTimeMin, userID,
+		req.Calories, req.ProteinG, req.CarbsG, req.FatG, servings, difficulty, stepsJSON).Scan(&id, &createdAt)
+	if err != nil {
+		return 0, err
+	}
+	if err := insertIngredients(tx, id, req.Ingredients); err != nil {
+		return 0, err
 	}
+	return id, nil
+}
 
-	for _, ri := range req.Ingredients {
-		r.db.Exec(`INSERT INTO recipe_ingredients (recipe_id, ingredient_id, quantity) VALUES ($1, $2, $3)`,
-			id, ri.IngredientID, ri.Quantity)
+// Create inserts a new recipe and its ingredients in a single transaction. userID is the creator (required).
+// A name the user already uses fails with *DuplicateRecipeError unless allowDuplicate is set.
+func (r *RecipeRepository) Create(req *models.CreateRecipeRequest, userID int, allowDuplicate bool) (*models.Recipe, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	created, _ := r.GetByID(id)
-	return created
+	id, err := insertRecipe(tx, req, userID, allowDuplicate)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(id)
 }
 
-// Update updates recipe and replaces its ingredients. Only the creator can update.
+// Clone copies recipe id, with its ingredients, into a new recipe named "Copy of ..."
+// owned by userID. Any user may clone any recipe; ratings and comments aren't copied.
+func (r *RecipeRepository) Clone(id int, userID int) (*models.Recipe, error) {
+	src, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	servings := src.Servings
+	req := &models.CreateRecipeRequest{
+		Name:         "Copy of " + src.Name,
+		Description:  src.Description,
+		Instructions: src.Instructions,
+		Steps:        src.Steps,
+		PrepTimeMin:  src.PrepTimeMin,
+		CookTimeMin:  src.CookTimeMin,
+		Servings:     &servings,
+		Difficulty:   src.Difficulty,
+		Calories:     src.Calories,
+		ProteinG:     src.ProteinG,
+		CarbsG:       src.CarbsG,
+		FatG:         src.FatG,
+	}
+	for _, ri := range src.Ingredients {
+		req.Ingredients = append(req.Ingredients, models.RecipeIngredient{
+			IngredientID: ri.IngredientID,
+			Quantity:     ri.Quantity,
+		})
+	}
+	return r.Create(req, userID, true)
+}
+
+// CreateBatch inserts several recipes in one transaction. Each recipe is written
+// behind a savepoint, so one with unknown or repeated ingredients or (unless
+// allowDuplicate) a name the user already has is rolled back on its own and reported
+// in itemErrs (as *InvalidIngredientsError, *DuplicateIngredientsError or
+// *DuplicateRecipeError) while the rest are kept.
+// Any other error aborts and rolls back the whole batch. ids[i] is 0 when itemErrs[i] is set.
+func (r *RecipeRepository) CreateBatch(reqs []*models.CreateRecipeRequest, userID int, allowDuplicate bool) (ids []int, itemErrs []error, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	ids = make([]int, len(reqs))
+	itemErrs = make([]error, len(reqs))
+	for i, req := range reqs {
+		if _, err := tx.Exec("SAVEPOINT import_item"); err != nil {
+			return nil, nil, err
+		}
+		id, err := insertRecipe(tx, req, userID, allowDuplicate)
+		var invalid *InvalidIngredientsError
+		var repeated *DuplicateIngredientsError
+		var duplicate *DuplicateRecipeError
+		if errors.As(err, &invalid) || errors.As(err, &repeated) || errors.As(err, &duplicate) {
+			if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT import_item"); rbErr != nil {
+				return nil, nil, rbErr
+			}
+			itemErrs[i] = err
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("import recipe %d: %w", i, err)
+		}
+		if _, err := tx.Exec("RELEASE SAVEPOINT import_item"); err != nil {
+			return nil, nil, err
+		}
+		ids[i] = id
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return ids, itemErrs, nil
+}
+
+// Update updates recipe and replaces its ingredients in a single transaction. Only the creator can update.
 func (r *RecipeRepository) Update(id int, req *models.UpdateRecipeRequest, userID int) (*models.Recipe, error) {
 	rec, err := r.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
-	if rec.UserID == nil || *rec.UserID != userID {
-		return nil, ErrRecipeForbidden
+	if err := r.checkOwner(rec.UserID, rec.Orphaned, userID); err != nil {
+		return nil, err
 	}
-	_, err = r.db.Exec(`UPDATE recipes SET name = $1, description = $2, instructions = $3, prep_time_min = $4, cook_time_min = $5 WHERE id = $6`,
-		req.Name, req.Description, req.Instructions, req.PrepTimeMin, req.CookTimeMin, id)
+	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+
+	if err := snapshotRecipe(tx, id, userID); err != nil {
+		return nil, fmt.Errorf("snapshot recipe %d: %w", id, err)
+	}
 
-	if _, err := r.db.Exec("DELETE FROM recipe_ingredients WHERE recipe_id = $1", id); err != nil {
+	servings := rec.Servings
+	if req.Servings != nil {
+		servings = *req.Servings
+	}
+	difficulty := rec.Difficulty
+	if req.Difficulty != "" {
+		difficulty = req.Difficulty
+	}
+	steps := rec.Steps
+	if req.Steps != nil {
+		steps = models.NormalizeSteps(req.Steps)
+	}
+	instructions := req.Instructions
+	if instructions == "" {
+		instructions = models.StepsText(steps)
+	}
+	stepsJSON, err := json.Marshal(steps)
+	if err != nil {
 		return nil, err
 	}
-	for _, ri := range req.Ingredients {
-		r.db.Exec(`INSERT INTO recipe_ingredients (recipe_id, ingredient_id, quantity) VALUES ($1, $2, $3)`,
-			id, ri.IngredientID, ri.Quantity)
+	_, err = tx.Exec(`UPDATE recipes SET name = $1, description = $2, instructions = $3, prep_time_min = $4, cook_time_min = $5,
+		calories = $6, protein_g = $7, carbs_g = $8, fat_g = $9, servings = $10, difficulty = $11, steps = $12,
+		updated_at = NOW() WHERE id = $13`,
+		req.Name, req.Description, instructions, req.PrepTimeMin, req.CookTimeMin,
+		req.Calories, req.ProteinG, req.CarbsG, req.FatG, servings, difficulty, stepsJSON, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM recipe_ingredients WHERE recipe_id = $1", id); err != nil {
+		return nil, err
+	}
+	if err := insertIngredients(tx, id, req.Ingredients); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
 	return r.GetByID(id)
 }
 
-// Delete removes a recipe. Only the creator can delete. Cascade deletes recipe_ingredients.
+// snapshotRecipe appends the recipe's current content to recipe_versions as its next
+// version, recording userID as the editor replacing it. The recipe row is locked so
+// concurrent updates get consecutive version numbers.
+func snapshotRecipe(tx *sql.Tx, id, userID int) error {
+	if _, err := tx.Exec(`SELECT id FROM recipes WHERE id = $1 FOR UPDATE`, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO recipe_versions (recipe_id, version, name, description, instructions,
+			prep_time_min, cook_time_min, servings, difficulty, steps, ingredients, replaced_by)
+		SELECT r.id, COALESCE((SELECT MAX(version) FROM recipe_versions WHERE recipe_id = r.id), 0) + 1,
+			r.name, r.description, r.instructions, r.prep_time_min, r.cook_time_min, r.servings, r.difficulty, r.steps,
+			COALESCE((SELECT jsonb_agg(jsonb_build_object(
+					'ingredient_id', ri.ingredient_id,
+					'ingredient', jsonb_build_object('id', i.id, 'name', i.name),
+					'quantity', ri.quantity) ORDER BY ri.ingredient_id)
+				FROM recipe_ingredients ri JOIN ingredients i ON i.id = ri.ingredient_id
+				WHERE ri.recipe_id = r.id), '[]'::jsonb),
+			$2
+		FROM recipes r WHERE r.id = $1`, id, userID)
+	return err
+}
+
+// versionColumns is the column list scanned by scanVersion.
+const versionColumns = `recipe_id, version, name, description, instructions, prep_time_min, cook_time_min,
+	servings, difficulty, steps, ingredients, replaced_by, replaced_at`
+
+func scanVersion(row rowScanner) (*models.RecipeVersion, error) {
+	var v models.RecipeVersion
+	var desc, instructions sql.NullString
+	var steps, ingredients []byte
+	var replacedBy sql.NullInt64
+	err := row.Scan(&v.RecipeID, &v.Version, &v.Name, &desc, &instructions, &v.PrepTimeMin, &v.CookTimeMin,
+		&v.Servings, &v.Difficulty, &steps, &ingredients, &replacedBy, &v.ReplacedAt)
+	if err != nil {
+		return nil, err
+	}
+	v.Description = desc.String
+	v.Instructions = instructions.String
+	if replacedBy.Valid {
+		uid := int(replacedBy.Int64)
+		v.ReplacedBy = &uid
+	}
+	if err := json.Unmarshal(steps, &v.Steps); err != nil {
+		return nil, fmt.Errorf("decode version steps: %w", err)
+	}
+	if v.Steps == nil {
+		v.Steps = []string{}
+	}
+	if err := json.Unmarshal(ingredients, &v.Ingredients); err != nil {
+		return nil, fmt.Errorf("decode version ingredients: %w", err)
+	}
+	for i := range v.Ingredients {
+		ri := &v.Ingredients[i]
+		ri.RecipeID = v.RecipeID
+		ri.Amount, ri.Unit, _ = models.ParseQuantity(ri.Quantity)
+	}
+	return &v, nil
+}
+
+// GetHistory returns a recipe's previous versions, newest first. Only the creator
+// may read the history.
+func (r *RecipeRepository) GetHistory(id, userID int) ([]*models.RecipeVersion, error) {
+	rec, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkOwner(rec.UserID, rec.Orphaned, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT `+versionColumns+`
+		FROM recipe_versions WHERE recipe_id = $1 ORDER BY version DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []*models.RecipeVersion{}
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// Revert makes a previous version the recipe's current content. It goes through
+// Update, so the content being replaced becomes a new version and history stays
+// append-only. Only the creator may revert; returns ErrVersionNotFound for an unknown version.
+func (r *RecipeRepository) Revert(id, version, userID int) (*models.Recipe, error) {
+	rec, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkOwner(rec.UserID, rec.Orphaned, userID); err != nil {
+		return nil, err
+	}
+
+	v, err := scanVersion(r.db.QueryRow(`SELECT `+versionColumns+`
+		FROM recipe_versions WHERE recipe_id = $1 AND version = $2`, id, version))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrVersionNotFound
+		}
+		return nil, err
+	}
+
+	req := &models.UpdateRecipeRequest{
+		Name:         v.Name,
+		Description:  v.Description,
+		Instructions: v.Instructions,
+		PrepTimeMin:  v.PrepTimeMin,
+		CookTimeMin:  v.CookTimeMin,
+		Steps:        v.Steps,
+		Servings:     &v.Servings,
+		Difficulty:   v.Difficulty,
+		// Nutrition isn't versioned; keep the current values.
+		Calories: rec.Calories,
+		ProteinG: rec.ProteinG,
+		CarbsG:   rec.CarbsG,
+		FatG:     rec.FatG,
+	}
+	for _, ri := range v.Ingredients {
+		req.Ingredients = append(req.Ingredients, models.RecipeIngredient{
+			IngredientID: ri.IngredientID,
+			Quantity:     ri.Quantity,
+		})
+	}
+	return r.Update(id, req, userID)
+}
+
+// Delete soft-deletes a recipe by setting deleted_at. Only the creator can delete.
+// Ingredients, ratings and comments are kept so the recipe can be restored.
 func (r *RecipeRepository) Delete(id int, userID int) error {
 	rec, err := r.GetByID(id)
 	if err != nil {
 		return err
 	}
-	if rec.UserID == nil || *rec.UserID != userID {
-		return ErrRecipeForbidden
+	if err := r.checkOwner(rec.UserID, rec.Orphaned, userID); err != nil {
+		return err
 	}
-	res, err := r.db.Exec("DELETE FROM recipes WHERE id = $1", id)
+	res, err := r.db.Exec("UPDATE recipes SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL", id)
 	if err != nil {
 		return err
 	}
@@ -169,6 +865,42 @@ func (r *RecipeRepository) Delete(id int, userID int) error {
 	return nil
 }
 
+// RestoreRecipe undoes a soft delete. Only the creator can restore; returns
+// ErrRecipeNotFound if the recipe doesn't exist or isn't deleted. The row is locked
+// while ownership is checked so a concurrent restore or purge can't slip in between.
+func (r *RecipeRepository) RestoreRecipe(id int, userID int) (*models.Recipe, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var ownerID sql.NullInt64
+	var orphaned bool
+	err = tx.QueryRow("SELECT user_id, orphaned FROM recipes WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE", id).Scan(&ownerID, &orphaned)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecipeNotFound
+		}
+		return nil, err
+	}
+	var owner *int
+	if ownerID.Valid {
+		uid := int(ownerID.Int64)
+		owner = &uid
+	}
+	if err := r.checkOwner(owner, orphaned, userID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE recipes SET deleted_at = NULL WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
 // SearchByName returns recipes whose name or description contains the query (case-insensitive).
 func (r *RecipeRepository) SearchByName(query string) []*models.Recipe {
 	query = strings.TrimSpace(strings.ToLower(query))
@@ -176,35 +908,14 @@ func (r *RecipeRepository) SearchByName(query string) []*models.Recipe {
 		return r.GetAll()
 	}
 	pattern := "%" + query + "%"
-	rows, err := r.db.Query(`SELECT id, name, description, instructions, prep_time_min, cook_time_min, user_id, created_at
-		FROM recipes WHERE LOWER(name) LIKE $1 OR LOWER(COALESCE(description,'')) LIKE $2 ORDER BY id`, pattern, pattern)
-	if err != nil {
-		return nil
-	}
-	defer rows.Close()
-
-	var list []*models.Recipe
-	for rows.Next() {
-		var rec models.Recipe
-		var desc, instructions sql.NullString
-		var userID sql.NullInt64
-		if err := rows.Scan(&rec.ID, &rec.Name, &desc, &instructions, &rec.PrepTimeMin, &rec.CookTimeMin, &userID, &rec.CreatedAt); err != nil {
-			continue
-		}
-		rec.Description = desc.String
-		rec.Instructions = instructions.String
-		if userID.Valid {
-			uid := int(userID.Int64)
-			rec.UserID = &uid
-		}
-		rec.Ingredients, _ = r.loadIngredients(rec.ID)
-		list = append(list, &rec)
-	}
-	return list
+	return r.queryRecipes(`deleted_at IS NULL AND (LOWER(name) LIKE $1 OR LOWER(COALESCE(description,'')) LIKE $2)`,
+		"id", 0, 0, pattern, pattern)
 }
 
-// SearchByIngredients returns recipes that contain ALL of the given ingredient names.
-func (r *RecipeRepository) SearchByIngredients(ingredientNames []string) []*models.Recipe {
+// SearchByIngredients returns recipes that contain ALL of the given ingredient names,
+// or with matchAny, recipes that contain at least one of them. Recipes matching more
+// of the names come first.
+func (r *RecipeRepository) SearchByIngredients(ingredientNames []string, matchAny bool) []*models.Recipe {
 	if len(ingredientNames) == 0 {
 		return r.GetAll()
 	}
@@ -219,7 +930,8 @@ func (r *RecipeRepository) SearchByIngredients(ingredientNames []string) []*mode
 		return r.GetAll()
 	}
 
-	// Recipe IDs that have ALL of the wanted ingredients (HAVING COUNT = len(want)).
+	// Recipe IDs that have any of the wanted ingredients; unless matchAny, only those
+	// that have ALL of them (HAVING COUNT = len(want)). GROUP BY dedupes the IDs.
 	args := make([]interface{}, 0, len(want)+1)
 	inParts := make([]string, 0, len(want))
 	pos := 1
@@ -228,9 +940,16 @@ func (r *RecipeRepository) SearchByIngredients(ingredientNames []string) []*mode
 		inParts = append(inParts, "$"+strconv.Itoa(pos))
 		pos++
 	}
-	args = append(args, len(want))
+	having := ""
+	if !matchAny {
+		args = append(args, len(want))
+		having = ` HAVING COUNT(DISTINCT LOWER(i.name)) = $` + strconv.Itoa(pos)
+	}
 	inPart := "LOWER(i.name) IN (" + strings.Join(inParts, ",") + ")"
-	q := `SELECT ri.recipe_id FROM recipe_ingredients ri JOIN ingredients i ON i.id = ri.ingredient_id WHERE ` + inPart + ` GROUP BY ri.recipe_id HAVING COUNT(DISTINCT LOWER(i.name)) = $` + strconv.Itoa(pos)
+	q := `SELECT ri.recipe_id FROM recipe_ingredients ri JOIN ingredients i ON i.id = ri.ingredient_id
+		JOIN recipes rec ON rec.id = ri.recipe_id
+		WHERE rec.deleted_at IS NULL AND ` + inPart + ` GROUP BY ri.recipe_id` + having + `
+		ORDER BY COUNT(DISTINCT LOWER(i.name)) DESC, ri.recipe_id`
 	rows, err := r.db.Query(q, args...)
 	if err != nil {
 		return nil
@@ -260,6 +979,76 @@ func (r *RecipeRepository) SearchByIngredients(ingredientNames []string) []*mode
 	return list
 }
 
+// GetPopular returns the highest-rated recipes with at least minVotes ratings, best
+// average first. One query ranks the recipes by their ratings and a second loads them
+// with their ingredients; unrated recipes are excluded.
+func (r *RecipeRepository) GetPopular(limit, minVotes int) ([]*models.PopularRecipe, error) {
+	rows, err := r.db.Query(`SELECT s.recipe_id, s.average_rating, s.total_ratings
+		FROM (
+			SELECT recipe_id, AVG(rating)::float8 AS average_rating, COUNT(*) AS total_ratings
+			FROM ratings
+			GROUP BY recipe_id
+			HAVING COUNT(*) >= $1
+		) s
+		JOIN recipes ON recipes.id = s.recipe_id AND recipes.deleted_at IS NULL
+		ORDER BY s.average_rating DESC, s.total_ratings DESC, s.recipe_id ASC
+		LIMIT $2`, minVotes, limit)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	stats := make(map[int]*models.PopularRecipe)
+	for rows.Next() {
+		var id int
+		p := &models.PopularRecipe{}
+		if err := rows.Scan(&id, &p.AverageRating, &p.TotalRatings); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		stats[id] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// GetByIDs keeps the ranking order and loads all ingredients in one query.
+	recipes, err := r.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*models.PopularRecipe, 0, len(recipes))
+	for _, rec := range recipes {
+		p := stats[rec.ID]
+		p.Recipe = rec
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// RecipeIDsWithMinRating returns the IDs of recipes averaging at least minRating
+// over at least minVotes ratings.
+func (r *RecipeRepository) RecipeIDsWithMinRating(minRating float64, minVotes int) (map[int]bool, error) {
+	rows, err := r.db.Query(`SELECT recipe_id FROM ratings
+		GROUP BY recipe_id
+		HAVING COUNT(*) >= $1 AND AVG(rating) >= $2`, minVotes, minRating)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
 // ListIngredients returns all ingredients.
 func (r *RecipeRepository) ListIngredients() []*models.Ingredient {
 	rows, err := r.db.Query("SELECT id, name FROM ingredients ORDER BY id")
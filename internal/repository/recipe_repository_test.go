@@ -0,0 +1,160 @@
+package repository_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"cooking-app/internal/db/dbtest"
+	"cooking-app/internal/models"
+	"cooking-app/internal/repository"
+)
+
+// createUser inserts a user for tests that need a recipe owner.
+func createUser(t *testing.T, conn *sql.DB, username string) *models.User {
+	t.Helper()
+	user, err := repository.NewUserRepository(conn).CreateWithPassword(username, username+"@example.com", "hash", "", "")
+	if err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return user
+}
+
+func TestRecipeRepositoryCreateAndGetByID(t *testing.T) {
+	conn := dbtest.Open(t)
+	repo := repository.NewRecipeRepository(conn)
+	owner := createUser(t, conn, "alice")
+
+	created, err := repo.Create(&models.CreateRecipeRequest{
+		Name:        "Omelette",
+		PrepTimeMin: 5,
+		CookTimeMin: 10,
+		Ingredients: []models.RecipeIngredient{
+			{Name: "Eggs", Quantity: "3"},
+			{Name: "Milk", Quantity: "50 ml"},
+		},
+	}, owner.ID, false)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := repo.GetByID(created.ID)
+	if err != nil {
+		t.Fatalf("GetByID(%d): %v", created.ID, err)
+	}
+	if got.Name != "Omelette" {
+		t.Errorf("Name = %q, want %q", got.Name, "Omelette")
+	}
+	if got.UserID == nil || *got.UserID != owner.ID {
+		t.Errorf("UserID = %v, want %d", got.UserID, owner.ID)
+	}
+	if len(got.Ingredients) != 2 {
+		t.Fatalf("got %d ingredients, want 2", len(got.Ingredients))
+	}
+	if got.Servings != 1 || got.Difficulty != models.DifficultyMedium {
+		t.Errorf("defaults: servings = %d, difficulty = %q; want 1, %q", got.Servings, got.Difficulty, models.DifficultyMedium)
+	}
+}
+
+// createLegacyRecipe inserts a recipe with no creator, as stored before ownership tracking.
+func createLegacyRecipe(t *testing.T, conn *sql.DB) int {
+	t.Helper()
+	var id int
+	if err := conn.QueryRow(`INSERT INTO recipes (name) VALUES ('Legacy stew') RETURNING id`).Scan(&id); err != nil {
+		t.Fatalf("insert legacy recipe: %v", err)
+	}
+	return id
+}
+
+func TestRecipeRepositoryOwnership(t *testing.T) {
+	conn := dbtest.Open(t)
+	owner := createUser(t, conn, "owner")
+	other := createUser(t, conn, "other")
+
+	tests := []struct {
+		name       string
+		lockLegacy bool
+		legacy     bool // recipe has no creator
+		orphaned   bool // recipe's creator deleted their account
+		missing    bool // recipe ID doesn't exist
+		userID     int
+		wantErr    error
+	}{
+		{name: "owner", userID: owner.ID},
+		{name: "non-owner", userID: other.ID, wantErr: repository.ErrRecipeForbidden},
+		{name: "legacy recipe", legacy: true, userID: other.ID},
+		{name: "locked legacy recipe", legacy: true, lockLegacy: true, userID: owner.ID, wantErr: repository.ErrRecipeForbidden},
+		{name: "orphaned recipe", orphaned: true, userID: other.ID, wantErr: repository.ErrRecipeForbidden},
+		{name: "missing recipe", missing: true, userID: owner.ID, wantErr: repository.ErrRecipeNotFound},
+	}
+
+	for _, tt := range tests {
+		for _, op := range []string{"Update", "Delete"} {
+			t.Run(tt.name+"/"+op, func(t *testing.T) {
+				repo := repository.NewRecipeRepository(conn)
+				repo.SetLegacyRecipesLocked(tt.lockLegacy)
+
+				var id int
+				switch {
+				case tt.missing:
+					id = 999999
+				case tt.legacy:
+					id = createLegacyRecipe(t, conn)
+				case tt.orphaned:
+					gone := createUser(t, conn, "gone_"+op)
+					rec, err := repo.Create(&models.CreateRecipeRequest{Name: "Soup"}, gone.ID, true)
+					if err != nil {
+						t.Fatalf("Create: %v", err)
+					}
+					if _, err := repository.NewUserRepository(conn).DeleteAccount(gone.ID); err != nil {
+						t.Fatalf("DeleteAccount: %v", err)
+					}
+					id = rec.ID
+				default:
+					rec, err := repo.Create(&models.CreateRecipeRequest{Name: "Soup"}, owner.ID, true)
+					if err != nil {
+						t.Fatalf("Create: %v", err)
+					}
+					id = rec.ID
+				}
+
+				var err error
+				if op == "Update" {
+					_, err = repo.Update(id, &models.UpdateRecipeRequest{Name: "Renamed"}, tt.userID)
+				} else {
+					err = repo.Delete(id, tt.userID)
+				}
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("%s error = %v, want %v", op, err, tt.wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestRecipeRepositoryDuplicateIngredients(t *testing.T) {
+	conn := dbtest.Open(t)
+	repo := repository.NewRecipeRepository(conn)
+	owner := createUser(t, conn, "cook")
+
+	req := &models.CreateRecipeRequest{
+		Name:        "Scrambled eggs",
+		Ingredients: []models.RecipeIngredient{{Name: "Eggs", Quantity: "2"}, {Name: "eggs", Quantity: "1"}},
+	}
+	var repeated *repository.DuplicateIngredientsError
+	if _, err := repo.Create(req, owner.ID, false); !errors.As(err, &repeated) {
+		t.Fatalf("Create error = %v, want *DuplicateIngredientsError", err)
+	}
+
+	ok := &models.CreateRecipeRequest{Name: "Toast", Ingredients: []models.RecipeIngredient{{Name: "Bread", Quantity: "1 slice"}}}
+	ids, itemErrs, err := repo.CreateBatch([]*models.CreateRecipeRequest{req, ok}, owner.ID, false)
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	if !errors.As(itemErrs[0], &repeated) {
+		t.Errorf("itemErrs[0] = %v, want *DuplicateIngredientsError", itemErrs[0])
+	}
+	if itemErrs[1] != nil || ids[1] == 0 {
+		t.Errorf("second recipe: id %d, err %v; want it imported", ids[1], itemErrs[1])
+	}
+}
@@ -3,15 +3,19 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"log"
 	"time"
 
 	"cooking-app/internal/models"
 )
 
 var (
-	ErrUserNotFound   = errors.New("user not found")
-	ErrUsernameExists = errors.New("username already exists")
-	ErrEmailExists    = errors.New("email already exists")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrUsernameExists      = errors.New("username already exists")
+	ErrEmailExists         = errors.New("email already exists")
+	ErrInvalidResetToken   = errors.New("reset token is invalid, expired or already used")
+	ErrInvalidVerifyToken  = errors.New("verification token is invalid, expired or already used")
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid, expired or revoked")
 )
 
 // UserRepository stores users in PostgreSQL (thread-safe via connection pool).
@@ -24,17 +28,15 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-// GetByID returns a user by ID.
-func (r *UserRepository) GetByID(id int) (*models.User, error) {
-	row := r.db.QueryRow(`SELECT id, username, email, password, first_name, last_name, bio, created_at
-		FROM users WHERE id = $1`, id)
+// userColumns is the column list scanned by scanUser.
+const userColumns = `id, username, email, password, first_name, last_name, bio, email_verified, created_at, updated_at`
+
+// scanUser scans the userColumns of one row from *sql.Row or *sql.Rows.
+func scanUser(row rowScanner) (*models.User, error) {
 	var u models.User
 	var firstName, lastName, bio sql.NullString
-	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &firstName, &lastName, &bio, &u.CreatedAt)
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &firstName, &lastName, &bio, &u.EmailVerified, &u.CreatedAt, &u.UpdatedAt)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrUserNotFound
-		}
 		return nil, err
 	}
 	u.FirstName = firstName.String
@@ -43,47 +45,51 @@ func (r *UserRepository) GetByID(id int) (*models.User, error) {
 	return &u, nil
 }
 
+// GetByID returns a user by ID.
+func (r *UserRepository) GetByID(id int) (*models.User, error) {
+	row := r.db.QueryRow(`SELECT `+userColumns+`
+		FROM users WHERE id = $1`, id)
+	u, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
 // GetByUsername returns a user by username.
 func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
-	row := r.db.QueryRow(`SELECT id, username, email, password, first_name, last_name, bio, created_at
+	row := r.db.QueryRow(`SELECT `+userColumns+`
 		FROM users WHERE username = $1`, username)
-	var u models.User
-	var firstName, lastName, bio sql.NullString
-	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &firstName, &lastName, &bio, &u.CreatedAt)
+	u, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
-	u.FirstName = firstName.String
-	u.LastName = lastName.String
-	u.Bio = bio.String
-	return &u, nil
+	return u, nil
 }
 
 // GetByEmail returns a user by email.
 func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
-	row := r.db.QueryRow(`SELECT id, username, email, password, first_name, last_name, bio, created_at
+	row := r.db.QueryRow(`SELECT `+userColumns+`
 		FROM users WHERE email = $1`, email)
-	var u models.User
-	var firstName, lastName, bio sql.NullString
-	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &firstName, &lastName, &bio, &u.CreatedAt)
+	u, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
-	u.FirstName = firstName.String
-	u.LastName = lastName.String
-	u.Bio = bio.String
-	return &u, nil
+	return u, nil
 }
 
 // GetAll returns all users.
 func (r *UserRepository) GetAll() []*models.User {
-	rows, err := r.db.Query(`SELECT id, username, email, password, first_name, last_name, bio, created_at FROM users ORDER BY id`)
+	rows, err := r.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY id`)
 	if err != nil {
 		return nil
 	}
@@ -91,15 +97,11 @@ func (r *UserRepository) GetAll() []*models.User {
 
 	var users []*models.User
 	for rows.Next() {
-		var u models.User
-		var firstName, lastName, bio sql.NullString
-		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &firstName, &lastName, &bio, &u.CreatedAt); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			continue
 		}
-		u.FirstName = firstName.String
-		u.LastName = lastName.String
-		u.Bio = bio.String
-		users = append(users, &u)
+		users = append(users, u)
 	}
 	return users
 }
@@ -116,6 +118,7 @@ func (r *UserRepository) Create(user *models.User) *models.User {
 	}
 	user.ID = id
 	user.CreatedAt = createdAt
+	user.UpdatedAt = createdAt
 	return user
 }
 
@@ -149,12 +152,13 @@ func (r *UserRepository) CreateWithPassword(username, email, hashedPassword, fir
 		FirstName: firstName,
 		LastName:  lastName,
 		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
 	}, nil
 }
 
 // Update updates first_name, last_name, bio by ID.
 func (r *UserRepository) Update(id int, req *models.UpdateUserRequest) (*models.User, error) {
-	res, err := r.db.Exec(`UPDATE users SET first_name = $1, last_name = $2, bio = $3 WHERE id = $4`,
+	res, err := r.db.Exec(`UPDATE users SET first_name = $1, last_name = $2, bio = $3, updated_at = NOW() WHERE id = $4`,
 		req.FirstName, req.LastName, req.Bio, id)
 	if err != nil {
 		return nil, err
@@ -166,9 +170,228 @@ func (r *UserRepository) Update(id int, req *models.UpdateUserRequest) (*models.
 	return r.GetByID(id)
 }
 
+// UpdatePassword replaces the user's bcrypt password hash and, in the same
+// transaction, revokes their refresh tokens and unused reset tokens.
+func (r *UserRepository) UpdatePassword(id int, hashedPassword string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE users SET password = $1 WHERE id = $2`, hashedPassword, id)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	if err := revokeCredentials(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// revokeCredentials ends every refresh token session of userID and invalidates their
+// outstanding reset tokens, so a password change locks out whoever knew the old one.
+func revokeCredentials(tx *sql.Tx, userID int) error {
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL`, userID)
+	return err
+}
+
+// CreatePasswordResetToken stores the digest of a reset token for userID, valid until expiresAt.
+func (r *UserRepository) CreatePasswordResetToken(userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := r.db.Exec(`INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`,
+		userID, tokenHash, expiresAt)
+	return err
+}
+
+// ResetPassword marks an unexpired, unused reset token as used and sets the user's new
+// password hash in one transaction, revoking the user's refresh tokens and other reset
+// tokens with it. Returns ErrInvalidResetToken if the token can't be used.
+func (r *UserRepository) ResetPassword(tokenHash, hashedPassword string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(`UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`, tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET password = $1 WHERE id = $2`, hashedPassword, userID); err != nil {
+		return err
+	}
+	if err := revokeCredentials(tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateEmailVerificationToken stores the digest of an email verification token for userID.
+func (r *UserRepository) CreateEmailVerificationToken(userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := r.db.Exec(`INSERT INTO email_verification_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`,
+		userID, tokenHash, expiresAt)
+	return err
+}
+
+// VerifyEmail consumes an unexpired, unused verification token and marks the owner's
+// email as verified. Returns the verified user or ErrInvalidVerifyToken.
+func (r *UserRepository) VerifyEmail(tokenHash string) (*models.User, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(`UPDATE email_verification_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`, tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidVerifyToken
+		}
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET email_verified = TRUE WHERE id = $1`, userID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(userID)
+}
+
+// CreateRefreshToken stores the digest of a refresh token for userID.
+func (r *UserRepository) CreateRefreshToken(userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := r.db.Exec(`INSERT INTO refresh_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`,
+		userID, tokenHash, expiresAt)
+	return err
+}
+
+// GetUserByRefreshToken returns the owner of an unexpired, unrevoked refresh token.
+func (r *UserRepository) GetUserByRefreshToken(tokenHash string) (*models.User, error) {
+	var userID int
+	err := r.db.QueryRow(`SELECT user_id FROM refresh_tokens
+		WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()`, tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	return r.GetByID(userID)
+}
+
+// RevokeRefreshToken marks a refresh token as revoked.
+func (r *UserRepository) RevokeRefreshToken(tokenHash string) error {
+	res, err := r.db.Exec(`UPDATE refresh_tokens SET revoked_at = NOW() WHERE token_hash = $1 AND revoked_at IS NULL`, tokenHash)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+// RevokeToken adds an access token's jti to the denylist until expiresAt.
+func (r *UserRepository) RevokeToken(jti string, expiresAt time.Time) error {
+	_, err := r.db.Exec(`INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING`, jti, expiresAt)
+	return err
+}
+
+// IsTokenRevoked reports whether an access token's jti is on the denylist.
+func (r *UserRepository) IsTokenRevoked(jti string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	return revoked, err
+}
+
+// PurgeRevokedTokens drops denylist entries for tokens that have expired anyway.
+func (r *UserRepository) PurgeRevokedTokens() (int64, error) {
+	res, err := r.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// StartRevokedTokenCleanup runs PurgeRevokedTokens every interval in a background
+// goroutine. An interval <= 0 disables it.
+func (r *UserRepository) StartRevokedTokenCleanup(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := r.PurgeRevokedTokens(); err != nil {
+				log.Printf("Warning: revoked token cleanup failed: %v", err)
+			}
+		}
+	}()
+}
+
+// IsEmailVerified reports whether the user has verified their email address.
+func (r *UserRepository) IsEmailVerified(id int) (bool, error) {
+	var verified bool
+	err := r.db.QueryRow(`SELECT email_verified FROM users WHERE id = $1`, id).Scan(&verified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrUserNotFound
+		}
+		return false, err
+	}
+	return verified, nil
+}
+
+// IsAdmin reports whether the user may use the moderation endpoints. Admins are
+// granted directly in the database (UPDATE users SET is_admin = TRUE ...).
+func (r *UserRepository) IsAdmin(id int) (bool, error) {
+	var admin bool
+	err := r.db.QueryRow(`SELECT is_admin FROM users WHERE id = $1`, id).Scan(&admin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrUserNotFound
+		}
+		return false, err
+	}
+	return admin, nil
+}
+
 // Delete removes a user by ID.
 func (r *UserRepository) Delete(id int) error {
-	res, err := r.db.Exec("DELETE FROM users WHERE id = $1", id)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(invalidateUserRatingCacheSQL, id); err != nil {
+		return err
+	}
+	res, err := tx.Exec("DELETE FROM users WHERE id = $1", id)
 	if err != nil {
 		return err
 	}
@@ -176,5 +399,54 @@ func (r *UserRepository) Delete(id int) error {
 	if n == 0 {
 		return ErrUserNotFound
 	}
-	return nil
+	return tx.Commit()
+}
+
+// DeleteAccount deletes a user in one transaction and reports what went with them.
+// Ratings, comments (and replies to them), favorites and tokens are removed by the
+// ON DELETE CASCADE foreign keys; the user's recipes are kept with user_id set to NULL
+// and marked orphaned, which makes them read-only.
+func (r *UserRepository) DeleteAccount(id int) (*models.AccountDeletionSummary, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var locked int
+	if err := tx.QueryRow(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, id).Scan(&locked); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	var s models.AccountDeletionSummary
+	err = tx.QueryRow(`WITH RECURSIVE doomed AS (
+			SELECT id FROM comments WHERE user_id = $1
+			UNION
+			SELECT c.id FROM comments c JOIN doomed d ON c.parent_id = d.id
+		)
+		SELECT (SELECT COUNT(*) FROM ratings WHERE user_id = $1),
+			(SELECT COUNT(*) FROM doomed),
+			(SELECT COUNT(*) FROM favorites WHERE user_id = $1),
+			(SELECT COUNT(*) FROM recipes WHERE user_id = $1)`, id).
+		Scan(&s.RatingsDeleted, &s.CommentsDeleted, &s.FavoritesDeleted, &s.RecipesOrphaned)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(invalidateUserRatingCacheSQL, id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE recipes SET orphaned = TRUE WHERE user_id = $1", id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM users WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &s, nil
 }
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// viewFlushInterval is how often buffered recipe views are written to the database.
+	viewFlushInterval = 30 * time.Second
+	// viewDedupeWindow is how long repeat views of a recipe by the same signed-in user
+	// are ignored.
+	viewDedupeWindow = 10 * time.Minute
+)
+
+// recipeView is one GetRecipe call; UserID is 0 for anonymous viewers.
+type recipeView struct {
+	RecipeID int
+	UserID   int
+}
+
+// viewKey identifies a signed-in user's view of a recipe for deduplication.
+type viewKey struct {
+	recipeID, userID int
+}
+
+// ViewCounter counts recipe views asynchronously, the way ActivityLogger writes
+// events: Record only queues the view, and a background goroutine adds up the queued
+// views and writes them to recipes.view_count in one UPDATE per flush interval.
+type ViewCounter struct {
+	db      *sql.DB
+	views   chan recipeView
+	done    chan struct{} // closed once run has flushed the last views
+	dropped atomic.Uint64 // views discarded because the channel was full or closed
+
+	mu     sync.RWMutex // held for reading while sending on views, for writing by Close
+	closed bool
+}
+
+// NewViewCounter creates the counter and starts its goroutine.
+func NewViewCounter(db *sql.DB) *ViewCounter {
+	c := &ViewCounter{
+		db:    db,
+		views: make(chan recipeView, 1000),
+		done:  make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Record queues a view of recipeID by userID (0 if anonymous) without blocking. A view
+// is dropped when the queue is full or the counter has been closed.
+func (c *ViewCounter) Record(recipeID, userID int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		c.dropped.Add(1)
+		return
+	}
+	select {
+	case c.views <- recipeView{RecipeID: recipeID, UserID: userID}:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many views have been discarded because the queue was full.
+func (c *ViewCounter) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+func (c *ViewCounter) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(viewFlushInterval)
+	defer ticker.Stop()
+
+	pending := make(map[int]int)            // recipe ID -> views not yet written
+	lastSeen := make(map[viewKey]time.Time) // last counted view per signed-in user
+
+	for {
+		select {
+		case v, ok := <-c.views:
+			if !ok {
+				c.flush(pending)
+				return
+			}
+			if v.UserID != 0 {
+				key := viewKey{v.RecipeID, v.UserID}
+				now := time.Now()
+				if seen, ok := lastSeen[key]; ok && now.Sub(seen) < viewDedupeWindow {
+					continue
+				}
+				lastSeen[key] = now
+			}
+			pending[v.RecipeID]++
+		case <-ticker.C:
+			c.flush(pending)
+			for key, seen := range lastSeen {
+				if time.Since(seen) >= viewDedupeWindow {
+					delete(lastSeen, key)
+				}
+			}
+		}
+	}
+}
+
+// flush adds pending to recipes.view_count and empties it. On failure the views are
+// logged and discarded; view counts are approximate.
+func (c *ViewCounter) flush(pending map[int]int) {
+	if len(pending) == 0 {
+		return
+	}
+	ids := make([]int, 0, len(pending))
+	counts := make([]int, 0, len(pending))
+	for id, n := range pending {
+		ids = append(ids, id)
+		counts = append(counts, n)
+	}
+	clear(pending)
+
+	_, err := c.db.Exec(`
+		UPDATE recipes r SET view_count = r.view_count + v.n
+		FROM unnest($1::int[], $2::int[]) AS v(id, n)
+		WHERE r.id = v.id`, ids, counts)
+	if err != nil {
+		log.Printf("Warning: failed to record %d recipe view counts: %v", len(ids), err)
+	}
+}
+
+// Close stops accepting views and waits until the queued ones have been written.
+// Views recorded after Close are dropped, so handlers still running when a graceful
+// shutdown times out cannot panic on the closed channel.
+func (c *ViewCounter) Close() {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.views)
+	}
+	c.mu.Unlock()
+	<-c.done
+}